@@ -0,0 +1,431 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// CheckLinksCommand is the subcommand to check the links of converted posts
+const CheckLinksCommand = "check-links"
+
+// LinkReportFileName is the name of the report written by the link checker
+const LinkReportFileName = "link-report.txt"
+
+// Link kinds found in the converted posts
+const (
+	LinkInternal = "internal" // a link to a converted post
+	LinkAnchor   = "anchor"   // a link to an anchor within the same post
+	LinkImage    = "image"    // an image
+	LinkExternal = "external" // a link to an external site
+	LinkMedium   = "medium"   // a link still pointing to Medium
+	LinkOther    = "other"    // a mailto:, tel: or other non web link, not checked
+)
+
+// A Link is a link found in a converted post
+type Link struct {
+	// the markdown file the link was found in
+	File string
+	URL  string
+	Kind string
+	// why the link is broken, empty if the link is fine or wasn't checked
+	Problem string
+}
+
+// A ConvertedPost holds the details of a converted post read back from the
+// output, used to check the links between the posts
+type ConvertedPost struct {
+	Post
+	Content string
+}
+
+// markdownLinkPatterns match the links in the converted markdown, the first
+// group being the url
+var markdownLinkPatterns = []*regexp.Regexp{
+	// [text](url "title"), ![alt](url), [text]({{< relref "post.md" >}})
+	regexp.MustCompile(`\]\((\{\{<.*?>\}\}|[^)\s]+)(?:\s+"[^"]*")?\)`),
+	// [1]: url, but not the [^1]: footnotes
+	regexp.MustCompile(`(?m)^\[[^\]^][^\]]*\]:\s+(\S+)`),
+	// <a href="url">, <img src="url">
+	regexp.MustCompile(`\s(?:href|src)="([^"]+)"`),
+}
+
+// frontMatterDelimiter matches the lines opening and closing the front matter
+// of the converted posts
+var frontMatterDelimiter = regexp.MustCompile(`(?m)^---\s*$`)
+
+// relrefFile matches the file name of a ref or relref shortcode, without the
+// fragment
+var relrefFile = regexp.MustCompile(`"([^"#]+)`)
+
+// runCheckLinks parses the check-links subcommand arguments and checks the
+// links of the posts in the given output directory, writing a report of the
+// broken links and the links still pointing to Medium
+func runCheckLinks(args []string) {
+	fs := flag.NewFlagSet(CheckLinksCommand, flag.ExitOnError)
+	dir := fs.String("d", "", "the output directory of a conversion, the out directory in medium-to-hugo_<date>_<time>")
+	external := fs.Bool("external", false, "check the external links with HEAD requests")
+	permalink := fs.String("permalink", DefaultPermalink, "the template of the path of the converted posts, as used for the conversion")
+	report := fs.String("report", "", "the file to write the report to, defaults to "+LinkReportFileName+" in the output directory")
+	_ = fs.Parse(args)
+
+	exists, outPath := fileExists(*dir)
+	if !exists || len(*dir) == 0 {
+		printError("couldn't find the output directory: %s", *dir)
+		os.Exit(1)
+	}
+
+	if len(*report) == 0 {
+		*report = filepath.Join(outPath, LinkReportFileName)
+	}
+
+	mgr := &ConverterManager{
+		OutputPath: outPath,
+		PostsPath:  filepath.Join(outPath, HContentType),
+		Config:     Config{Permalink: *permalink},
+	}
+
+	posts, err := mgr.ReadConvertedPosts()
+	if err != nil {
+		printError("error while reading converted posts: %s", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Posts to check: \t%s\n", boldf("%d", len(posts)))
+
+	links := mgr.CheckLinks(posts, *external)
+
+	broken, medium := 0, 0
+	for _, l := range links {
+		if len(l.Problem) > 0 {
+			broken++
+		}
+
+		if l.Kind == LinkMedium {
+			medium++
+		}
+	}
+
+	err = writeLinkReport(*report, links)
+	if err != nil {
+		printError("couldn't write the link report: %s", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Links checked: \t\t%s\n", boldf("%d", len(links)))
+	if broken > 0 {
+		color.Red("Broken links: \t\t%d", broken)
+	}
+
+	if medium > 0 {
+		color.Yellow("Medium links: \t\t%d", medium)
+	}
+
+	fmt.Printf("Report: %s\n", color.New(color.FgGreen, color.Bold).Sprint(*report))
+}
+
+// ReadConvertedPosts reads the markdown files in the posts directory of the
+// output, along with the front matter values needed to know the paths of the
+// posts on the new site
+func (mgr *ConverterManager) ReadConvertedPosts() ([]*ConvertedPost, error) {
+	files, err := ioutil.ReadDir(mgr.PostsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	posts := make([]*ConvertedPost, 0)
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != MarkdownFileExtension {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(mgr.PostsPath, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		posts = append(posts, newConvertedPost(f.Name(), string(content)))
+	}
+
+	return posts, nil
+}
+
+// newConvertedPost creates a ConvertedPost from the given markdown file name
+// and content, reading the details of the post from the front matter
+func newConvertedPost(name, content string) *ConvertedPost {
+	p := &ConvertedPost{Content: content}
+	p.MdFilename = name
+	p.readFrontMatter()
+
+	// the original url is optional, but the default footer links to the post on Medium
+	if len(p.FullURL) == 0 {
+		if m := footerMediumLink.FindStringSubmatch(p.Content); m != nil {
+			p.SetFullURL(m[1])
+		}
+	}
+
+	return p
+}
+
+// footerMediumLink matches the link to the post on Medium in the default
+// footer, Originally published on [Medium](url)
+var footerMediumLink = regexp.MustCompile(`(?m)^Originally published on \[Medium\]\((\S+)\)\s*$`)
+
+// readFrontMatter reads the title, date, original url and the aliases from the
+// front matter of the converted post
+func (p *ConvertedPost) readFrontMatter() {
+	frontMatter, _, found := splitFrontMatter(p.Content)
+	if !found {
+		return
+	}
+
+	inAliases := false
+	for _, line := range strings.Split(frontMatter, "\n") {
+		line = strings.TrimSpace(line)
+		if inAliases && strings.HasPrefix(line, "- ") {
+			p.Aliases = append(p.Aliases, unquote(strings.TrimPrefix(line, "- ")))
+			continue
+		}
+
+		inAliases = line == "aliases:"
+		pieces := strings.SplitN(line, ":", 2)
+		if len(pieces) < 2 {
+			continue
+		}
+
+		value := unquote(strings.TrimSpace(pieces[1]))
+		switch pieces[0] {
+		case "title":
+			p.Title = value
+		case "date":
+			p.Date = value
+		case "originalUrl":
+			p.SetFullURL(value)
+		}
+	}
+}
+
+// splitFrontMatter splits the given markdown content in to the front matter
+// and the body. The front matter is delimited by --- lines at the beginning of
+// the content, so that a --- in a title or a section separator in the body
+// isn't taken as a delimiter.
+func splitFrontMatter(content string) (string, string, bool) {
+	delimiters := frontMatterDelimiter.FindAllStringIndex(content, 2)
+	if len(delimiters) < 2 || delimiters[0][0] != 0 {
+		return "", content, false
+	}
+
+	return content[delimiters[0][1]:delimiters[1][0]], content[delimiters[1][1]:], true
+}
+
+// CheckLinks classifies the links of the given converted posts, and checks
+// whether the internal links and images point to existing posts and files.
+// If external is true, the external links are checked with HEAD requests.
+func (mgr *ConverterManager) CheckLinks(posts []*ConvertedPost, external bool) []*Link {
+	// the paths of the posts on the new site, including the aliases
+	paths := make(map[string]bool)
+	files := make(map[string]bool)
+	for _, p := range posts {
+		files[p.MdFilename] = true
+		for _, a := range p.Aliases {
+			paths[strings.TrimSuffix(a, "/")] = true
+		}
+
+		if path, err := mgr.SitePath(&p.Post); err == nil {
+			paths[strings.TrimSuffix(path, "/")] = true
+		}
+	}
+
+	client := newHTTPClient()
+	checked := make(map[string]string)
+
+	links := make([]*Link, 0)
+	for _, p := range posts {
+		// skip the front matter
+		_, body, _ := splitFrontMatter(p.Content)
+
+		ownID := mediumPostID(p.FullURL)
+		for _, pattern := range markdownLinkPatterns {
+			for _, m := range pattern.FindAllStringSubmatch(body, -1) {
+				l := &Link{File: p.MdFilename, URL: m[1], Kind: classifyLink(m[0], m[1])}
+
+				if l.Kind == LinkOther {
+					continue
+				}
+
+				// the footer links to the post on Medium, or its custom domain, on purpose
+				if (l.Kind == LinkMedium || l.Kind == LinkExternal) && len(ownID) > 0 && mediumPostID(l.URL) == ownID {
+					continue
+				}
+
+				switch l.Kind {
+				case LinkInternal:
+					l.Problem = checkInternalLink(l.URL, paths, files)
+				case LinkImage:
+					l.Problem = mgr.checkImage(l.URL)
+				case LinkExternal:
+					if external {
+						if _, exists := checked[l.URL]; !exists {
+							checked[l.URL] = checkExternalLink(client, l.URL)
+						}
+
+						l.Problem = checked[l.URL]
+					}
+				}
+
+				links = append(links, l)
+			}
+		}
+	}
+
+	return links
+}
+
+// classifyLink determines the kind of the given link, using the markdown it
+// was found in to tell the images apart. Links with a scheme other than http
+// and https, like mailto: and tel:, can't be checked and are of LinkOther kind.
+func classifyLink(match, link string) string {
+	if strings.HasPrefix(link, "{{<") {
+		return LinkInternal
+	}
+
+	if strings.HasPrefix(link, "#") {
+		return LinkAnchor
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return LinkExternal
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "":
+		// a relative or a protocol relative (//example.com) link
+	default:
+		return LinkOther
+	}
+
+	hostname := u.Hostname()
+	if isMediumHost(hostname) {
+		return LinkMedium
+	}
+
+	if len(hostname) > 0 {
+		return LinkExternal
+	}
+
+	if strings.HasPrefix(match, " src=") || strings.HasPrefix(u.Path, "/"+HContentType+"/"+HImagesDirName+"/") {
+		return LinkImage
+	}
+
+	return LinkInternal
+}
+
+// checkInternalLink checks whether the given internal link points to one of
+// the given post paths or files, returns the problem if not
+func checkInternalLink(link string, paths, files map[string]bool) string {
+	// {{< relref "2018-09-25_a-b-tests-developers-manual.md#3f51" >}}
+	if strings.HasPrefix(link, "{{<") {
+		ref := relrefFile.FindStringSubmatch(link)
+		if ref == nil || !files[filepath.Base(ref[1])] {
+			return "post not found"
+		}
+
+		return ""
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return "invalid url"
+	}
+
+	if !paths[strings.TrimSuffix(u.Path, "/")] {
+		return "post not found"
+	}
+
+	return ""
+}
+
+// checkImage checks whether the given local image exists in the output,
+// returns the problem if not
+func (mgr *ConverterManager) checkImage(src string) string {
+	u, err := url.Parse(src)
+	if err != nil {
+		return "invalid url"
+	}
+
+	// the #layout suffix of the image is for styling
+	if exists, _ := fileExists(filepath.Join(mgr.OutputPath, filepath.FromSlash(u.Path))); !exists {
+		return "image not found"
+	}
+
+	return ""
+}
+
+// checkExternalLink checks the given external link with a HEAD request,
+// returns the problem if the link is broken. Some servers don't allow HEAD
+// requests, the link is checked with a GET request for those.
+func checkExternalLink(client *http.Client, link string) string {
+	res, err := client.Head(link)
+	if err == nil && res.StatusCode == http.StatusMethodNotAllowed {
+		res.Body.Close()
+		res, err = client.Get(link)
+	}
+
+	if err != nil {
+		return err.Error()
+	}
+
+	res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return res.Status
+	}
+
+	return ""
+}
+
+// writeLinkReport writes the broken links and the links still pointing to
+// Medium to the given file
+func writeLinkReport(f string, links []*Link) error {
+	var b strings.Builder
+
+	b.WriteString("Broken links\n============\n")
+	for _, l := range links {
+		if len(l.Problem) > 0 {
+			b.WriteString(fmt.Sprintf("%s: [%s] %s => %s\n", l.File, l.Kind, l.URL, l.Problem))
+		}
+	}
+
+	b.WriteString("\nMedium links\n============\n")
+	for _, l := range links {
+		if l.Kind == LinkMedium {
+			b.WriteString(fmt.Sprintf("%s: %s\n", l.File, l.URL))
+		}
+	}
+
+	return ioutil.WriteFile(f, []byte(b.String()), 0644)
+}
+
+// unquote removes the quotes around a front matter value, if any
+func unquote(v string) string {
+	if unquoted, err := strconv.Unquote(v); err == nil {
+		return unquoted
+	}
+
+	// the values aren't escaped in the template
+	if len(v) > 1 && strings.HasPrefix(v, `"`) && strings.HasSuffix(v, `"`) {
+		return v[1 : len(v)-1]
+	}
+
+	return v
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// convertedPost renders a converted post with the default footer and without
+// the original url in the front matter, as written with the default options
+func convertedPost(title, date, mediumURL, body string) string {
+	return "---\ntitle: \"" + title + "\"\ndate: " + date + "\n---\n\n" + body + "\n\n" +
+		"* * *\nWritten on January 10, 2019 by Chamila.\n\nOriginally published on [Medium](" + mediumURL + ")\n"
+}
+
+func TestCheckLinks(t *testing.T) {
+	out := t.TempDir()
+	postsPath := filepath.Join(out, HContentType)
+	if err := os.MkdirAll(filepath.Join(postsPath, HImagesDirName), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"2019-01-10_first.md": convertedPost(
+			"First",
+			"2019-01-10T10:00:00.000Z",
+			"https://medium.com/@chamilad/first-1a2b3c4d5e6f",
+			"See [the second post](/post/2019-02-01_second/), [a missing post](/post/missing/), "+
+				"[the second post on Medium](https://medium.com/@chamilad/second-6f5e4d3c2b1a) and "+
+				"![an image](/post/img/2019-01-10_first_0.png) ![a missing image](/post/img/missing.png)"),
+		"2019-02-01_second.md": convertedPost(
+			"Second",
+			"2019-02-01T10:00:00.000Z",
+			"https://blog.example.com/second-6f5e4d3c2b1a",
+			"Back to [the first post]({{< relref \"2019-01-10_first.md\" >}})."),
+	}
+
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(postsPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := ioutil.WriteFile(filepath.Join(postsPath, HImagesDirName, "2019-01-10_first_0.png"), []byte{}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := &ConverterManager{
+		OutputPath: out,
+		PostsPath:  postsPath,
+		Config:     Config{Permalink: "/" + HContentType + "/{{ .Name }}/"},
+	}
+
+	posts, err := mgr.ReadConvertedPosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, l := range mgr.CheckLinks(posts, false) {
+		got = append(got, l.File+" "+l.Kind+" "+l.URL+" "+l.Problem)
+	}
+
+	want := []string{
+		"2019-01-10_first.md internal /post/2019-02-01_second/ ",
+		"2019-01-10_first.md internal /post/missing/ post not found",
+		"2019-01-10_first.md medium https://medium.com/@chamilad/second-6f5e4d3c2b1a ",
+		"2019-01-10_first.md image /post/img/2019-01-10_first_0.png ",
+		"2019-01-10_first.md image /post/img/missing.png image not found",
+		"2019-02-01_second.md internal {{< relref \"2019-01-10_first.md\" >}} ",
+	}
+
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestNewConvertedPostMediumURL(t *testing.T) {
+	p := newConvertedPost("2019-01-10_first.md", convertedPost(
+		"First", "2019-01-10T10:00:00.000Z", "https://medium.com/@chamilad/first-1a2b3c4d5e6f", "Text."))
+	if want := "https://medium.com/@chamilad/first-1a2b3c4d5e6f"; p.FullURL != want {
+		t.Fatalf("got medium url %q, want %q", p.FullURL, want)
+	}
+
+	mgr := &ConverterManager{Config: Config{Permalink: "/{{ .Canonical }}/{{ .ID }}/"}}
+	path, err := mgr.SitePath(&p.Post)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "/first-1a2b3c4d5e6f/1a2b3c4d5e6f/"; path != want {
+		t.Errorf("got path %s, want %s", path, want)
+	}
+}
+
+func TestReadFrontMatter(t *testing.T) {
+	p := newConvertedPost("2019-01-10_first.md", "---\n"+
+		"title: \"Before --- after\"\n"+
+		"date: 2019-01-10T10:00:00.000Z\n"+
+		"aliases:\n"+
+		"- \"/first-1a2b3c4d5e6f\"\n"+
+		"---\n\n"+
+		"First section.\n\n---\n\nSecond section, [a link](/post/second/).\n")
+
+	if want := "Before --- after"; p.Title != want {
+		t.Errorf("got title %q, want %q", p.Title, want)
+	}
+
+	if want := "2019-01-10T10:00:00.000Z"; p.Date != want {
+		t.Errorf("got date %q, want %q", p.Date, want)
+	}
+
+	if want := []string{"/first-1a2b3c4d5e6f"}; !reflect.DeepEqual(p.Aliases, want) {
+		t.Errorf("got aliases %v, want %v", p.Aliases, want)
+	}
+
+	// the section separator in the body isn't a front matter delimiter
+	links := (&ConverterManager{}).CheckLinks([]*ConvertedPost{p}, false)
+	if len(links) != 1 || links[0].URL != "/post/second/" {
+		t.Errorf("got links %v, want only /post/second/", links)
+	}
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		frontMatter string
+		body        string
+		found       bool
+	}{
+		{"front matter", "---\ntitle: a\n---\nbody\n", "\ntitle: a\n", "\nbody\n", true},
+		{"delimiter in a value", "---\ntitle: \"a---b\"\n---\nbody", "\ntitle: \"a---b\"\n", "\nbody", true},
+		{"trailing spaces", "--- \ntitle: a\n---  \nbody", "\ntitle: a\n", "\nbody", true},
+		{"separator in the body", "---\ntitle: a\n---\none\n---\ntwo", "\ntitle: a\n", "\none\n---\ntwo", true},
+		{"no front matter", "body\n---\nmore\n---\n", "", "body\n---\nmore\n---\n", false},
+		{"unclosed", "---\ntitle: a\n", "", "---\ntitle: a\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frontMatter, body, found := splitFrontMatter(tt.content)
+			if frontMatter != tt.frontMatter || body != tt.body || found != tt.found {
+				t.Errorf("got %q, %q, %t, want %q, %q, %t", frontMatter, body, found, tt.frontMatter, tt.body, tt.found)
+			}
+		})
+	}
+}
+
+func TestClassifyLink(t *testing.T) {
+	tests := []struct {
+		match string
+		link  string
+		want  string
+	}{
+		{`]({{< relref "first.md" >}})`, `{{< relref "first.md" >}}`, LinkInternal},
+		{"](#3f51)", "#3f51", LinkAnchor},
+		{"](/post/first/)", "/post/first/", LinkInternal},
+		{"](/post/img/first_0.png)", "/post/img/first_0.png", LinkImage},
+		{` src="/images/first.png"`, "/images/first.png", LinkImage},
+		{"](https://example.com/about)", "https://example.com/about", LinkExternal},
+		{"](HTTP://example.com/about)", "HTTP://example.com/about", LinkExternal},
+		{"](//example.com/about)", "//example.com/about", LinkExternal},
+		{"](https://medium.com/@chamilad/first-1a2b3c4d5e6f)", "https://medium.com/@chamilad/first-1a2b3c4d5e6f", LinkMedium},
+		{"](https://link.medium.com/AbCdEf)", "https://link.medium.com/AbCdEf", LinkMedium},
+		{"](mailto:someone@example.com)", "mailto:someone@example.com", LinkOther},
+		{"](tel:+94112345678)", "tel:+94112345678", LinkOther},
+		{"](javascript:void(0)", "javascript:void(0", LinkOther},
+		{"](data:image/png;base64,iVBORw0KGgo=)", "data:image/png;base64,iVBORw0KGgo=", LinkOther},
+		{"](ftp://example.com/file.txt)", "ftp://example.com/file.txt", LinkOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			if got := classifyLink(tt.match, tt.link); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExternalLinkTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// stall until the client gives up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	client := newHTTPClient()
+	client.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	problem := checkExternalLink(client, srv.URL)
+	if len(problem) == 0 {
+		t.Fatalf("the stalled link wasn't reported")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the request took %s, the client timeout wasn't applied", elapsed)
+	}
+}
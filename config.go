@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"text/template"
+)
+
+// Featured image selection strategies
+const (
+	FeaturedMarkedOrFirst = "auto"     // the marked image, or the first image of the post
+	FeaturedMarked        = "featured" // only the image Medium marked as featured
+	FeaturedFirst         = "first"    // the first image of the post
+	FeaturedLargest       = "largest"  // the image with the largest dimensions
+	FeaturedNone          = "none"     // never set a featured image
+)
+
+// Output targets
+const (
+	TargetHugo   = "hugo"   // Hugo flavoured Markdown, using shortcodes where useful
+	TargetHTML   = "html"   // portable Markdown, embeds are rendered as plain HTML
+	TargetJekyll = "jekyll" // Jekyll flavoured Markdown, using liquid tags of common plugins
+	TargetZola   = "zola"   // Zola flavoured Markdown, using shortcodes where useful
+)
+
+// Tweet rendering modes
+const (
+	TweetsEmbed  = "embed"  // embed the tweet as supported by the output target
+	TweetsStatic = "static" // render the tweet as a blockquote with the text, author and date
+)
+
+// Rendering modes of Medium link preview cards (mixtape embeds)
+const (
+	LinkCardsNone      = "none"      // render as a plain link with the title
+	LinkCardsShortcode = "shortcode" // render as a link-card shortcode of the output target
+	LinkCardsHTML      = "html"      // render as an HTML block
+)
+
+// Rendering modes of Medium pull quotes
+const (
+	PullquotesBlockquote = "blockquote" // render as a plain blockquote
+	PullquotesHTML       = "html"       // render as a blockquote with the pullquote class
+	PullquotesShortcode  = "shortcode"  // render as a pullquote shortcode of the output target
+)
+
+// Rendering modes of Medium highlights and quote annotations
+const (
+	HighlightsMark      = "mark"      // render as mark html elements
+	HighlightsShortcode = "shortcode" // render as a mark shortcode of the output target
+	HighlightsText      = "text"      // render as plain text
+)
+
+// Table of contents modes
+const (
+	TOCNone        = "none"         // no table of contents
+	TOCFrontMatter = "front-matter" // set toc: true in the front matter, for themes that render one
+	TOCInline      = "inline"       // insert a table of contents at the beginning of the post
+)
+
+// Medium paragraph anchor modes
+const (
+	ParagraphAnchorsNone       = "none"       // drop the paragraph anchors
+	ParagraphAnchorsHTML       = "html"       // keep as inline HTML anchors
+	ParagraphAnchorsAttributes = "attributes" // keep as block attributes, {#id}
+)
+
+// Handling of inline formatting (bold, italic text and links) in code blocks
+const (
+	CodeFormattingDrop   = "drop"   // drop the formatting silently
+	CodeFormattingReport = "report" // drop the formatting, and report the affected code blocks
+	CodeFormattingHTML   = "html"   // render the affected code blocks as HTML to keep the formatting
+)
+
+// DefaultFooter is the template of the footer appended to the posts by default
+const DefaultFooter = "* * *\nWritten on {{ .Published }} by {{ .Author }}.\n\nOriginally published on [Medium]({{ .MediumURL }})"
+
+// Canonical URL modes
+const (
+	CanonicalNone   = "none"   // no canonical url
+	CanonicalMedium = "medium" // the Medium post is the canonical, for Medium copies kept live
+	CanonicalSite   = "site"   // the converted post on the new site is the canonical
+)
+
+// DefaultPermalink is the template of the path of the converted posts on the
+// new site, matching the default Hugo permalinks
+const DefaultPermalink = "/" + HContentType + "/{{ .Name }}/"
+
+// DefaultAlias is the template of the alias added to the posts by default,
+// the last segment of the Medium url
+const DefaultAlias = "/{{ .Canonical }}"
+
+// canonicalURLKeys are the front matter keys of the canonical url, by output
+// target, as used by the common themes and plugins
+var canonicalURLKeys = map[string]string{
+	TargetHugo:   "canonicalURL",
+	TargetHTML:   "canonicalURL",
+	TargetJekyll: "canonical_url", // jekyll-seo-tag
+	TargetZola:   "canonical_url",
+}
+
+// Rendering modes of the links between the converted posts
+const (
+	InternalLinksPath   = "path"   // the path of the post on the new site
+	InternalLinksRef    = "ref"    // a Hugo ref shortcode, the absolute url validated at build time
+	InternalLinksRelref = "relref" // a Hugo relref shortcode, the relative url validated at build time
+)
+
+// Config collects the user provided options that change how the posts are
+// converted
+type Config struct {
+	// Ignore empty articles
+	IgnoreEmpty bool
+
+	// The static site generator the output is meant for
+	Target string
+
+	// How tweets should be rendered
+	Tweets string
+
+	// How Medium link preview cards should be rendered
+	LinkCards string
+
+	// The markdown to separate Medium sections with
+	SectionSeparator string
+
+	// How Medium pull quotes should be rendered
+	Pullquotes string
+
+	// Whether and how a table of contents should be added
+	TOC string
+
+	// Add slugified anchors to the headings, keeping the Medium anchors as
+	// alternates
+	HeadingAnchors bool
+
+	// Whether and how the Medium paragraph anchors should be kept
+	ParagraphAnchors string
+
+	// How Medium highlights and quote annotations should be rendered
+	Highlights string
+
+	// Add the highlights of the post to the front matter
+	HighlightsFrontMatter bool
+
+	// Convert superscript markers linked to trailing paragraphs to footnotes
+	Footnotes bool
+
+	// The template of the footer appended to the posts, empty for no footer
+	Footer string
+
+	// Add the Medium url of the post to the front matter as originalUrl
+	OriginalURL bool
+
+	// Whether the canonical url front matter should point to Medium or the
+	// new site
+	Canonical string
+
+	// The base url of the new site, used for site canonical urls
+	SiteURL string
+
+	// The template of the path of the converted posts on the new site
+	Permalink string
+
+	// How the links between the converted posts should be rendered
+	InternalLinks string
+
+	// The patterns of the query params to remove from the links and image
+	// urls, utm_* matches all the params starting with utm_
+	StripParams []string
+
+	// The templates of the aliases of the posts, the old paths that should
+	// redirect to the converted posts
+	Aliases []string
+
+	// The formats of the redirect files to generate from the aliases
+	Redirects []string
+
+	// The level the top level headings of the post should be shifted to, 0
+	// to keep the headings as they are
+	HeadingLevel int
+
+	// The strategy to use when picking the featured image of a post
+	FeaturedImageStrategy string
+
+	// Remove the featured image from the post body, useful when the theme
+	// renders the featured image as a cover
+	ExcludeFeaturedImage bool
+
+	// Infer the language of preformatted code blocks from their content
+	GuessCodeLanguages bool
+
+	// How inline formatting in code blocks should be handled
+	CodeFormatting string
+
+	// Code block languages keyed by file extension or file name, these take
+	// precedence over the known languages
+	Languages map[string]string
+
+	// Per post overrides, keyed by the HTML file name in the medium export
+	Overrides map[string]*PostOverride
+}
+
+// A PostOverride holds the values that should be used for a specific post
+// instead of the ones derived during the conversion
+type PostOverride struct {
+	// Index of the image (in the order they appear in the post) to be used as
+	// the featured image. A negative value will result in no featured image.
+	FeaturedImage *int `json:"featuredImage,omitempty"`
+
+	// Languages of the code blocks, keyed by the index of the code block (in
+	// the order they appear in the post). An empty value will result in no
+	// language for the code block.
+	CodeLanguages map[int]string `json:"codeLanguages,omitempty"`
+}
+
+// validate checks the values of the given Config and returns an error
+// describing the first invalid value found
+func (c *Config) validate() error {
+	switch c.Target {
+	case TargetHugo, TargetHTML, TargetJekyll, TargetZola:
+	default:
+		return fmt.Errorf("unknown output target: %s", c.Target)
+	}
+
+	switch c.Tweets {
+	case TweetsEmbed, TweetsStatic:
+	default:
+		return fmt.Errorf("unknown tweet rendering mode: %s", c.Tweets)
+	}
+
+	switch c.LinkCards {
+	case LinkCardsNone, LinkCardsShortcode, LinkCardsHTML:
+	default:
+		return fmt.Errorf("unknown link card rendering mode: %s", c.LinkCards)
+	}
+
+	switch c.Pullquotes {
+	case PullquotesBlockquote, PullquotesHTML, PullquotesShortcode:
+	default:
+		return fmt.Errorf("unknown pull quote rendering mode: %s", c.Pullquotes)
+	}
+
+	if c.HeadingLevel < 0 || c.HeadingLevel > 6 {
+		return fmt.Errorf("invalid heading level: %d", c.HeadingLevel)
+	}
+
+	switch c.TOC {
+	case TOCNone, TOCFrontMatter, TOCInline:
+	default:
+		return fmt.Errorf("unknown table of contents mode: %s", c.TOC)
+	}
+
+	switch c.ParagraphAnchors {
+	case ParagraphAnchorsNone, ParagraphAnchorsHTML, ParagraphAnchorsAttributes:
+	default:
+		return fmt.Errorf("unknown paragraph anchor mode: %s", c.ParagraphAnchors)
+	}
+
+	switch c.Highlights {
+	case HighlightsMark, HighlightsShortcode, HighlightsText:
+	default:
+		return fmt.Errorf("unknown highlight rendering mode: %s", c.Highlights)
+	}
+
+	switch c.CodeFormatting {
+	case CodeFormattingDrop, CodeFormattingReport, CodeFormattingHTML:
+	default:
+		return fmt.Errorf("unknown code formatting mode: %s", c.CodeFormatting)
+	}
+
+	switch c.Canonical {
+	case CanonicalNone, CanonicalMedium:
+	case CanonicalSite:
+		if len(c.SiteURL) == 0 {
+			return fmt.Errorf("the site url is needed for site canonical urls")
+		}
+	default:
+		return fmt.Errorf("unknown canonical url mode: %s", c.Canonical)
+	}
+
+	if _, err := template.New("permalink").Parse(c.Permalink); err != nil {
+		return fmt.Errorf("invalid permalink template: %s", err)
+	}
+
+	switch c.InternalLinks {
+	case InternalLinksPath:
+	case InternalLinksRef, InternalLinksRelref:
+		if c.Target != TargetHugo {
+			return fmt.Errorf("%s internal links are only supported by the hugo target", c.InternalLinks)
+		}
+	default:
+		return fmt.Errorf("unknown internal link mode: %s", c.InternalLinks)
+	}
+
+	for _, p := range c.StripParams {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid query param pattern: %s", p)
+		}
+	}
+
+	for _, a := range c.Aliases {
+		if _, err := template.New("alias").Parse(a); err != nil {
+			return fmt.Errorf("invalid alias template: %s", err)
+		}
+	}
+
+	for _, r := range c.Redirects {
+		if findRedirectFormat(r) == nil {
+			return fmt.Errorf("unknown redirect format: %s", r)
+		}
+
+		if r == "cloudflare" && len(c.SiteURL) == 0 {
+			return fmt.Errorf("the site url is needed for cloudflare redirects")
+		}
+	}
+
+	if _, err := template.New("footer").Parse(c.Footer); err != nil {
+		return fmt.Errorf("invalid footer template: %s", err)
+	}
+
+	switch c.FeaturedImageStrategy {
+	case FeaturedMarkedOrFirst, FeaturedMarked, FeaturedFirst, FeaturedLargest, FeaturedNone:
+	default:
+		return fmt.Errorf("unknown featured image strategy: %s", c.FeaturedImageStrategy)
+	}
+
+	return nil
+}
+
+// KeepParagraphAnchors reports whether the Medium paragraph anchors should be
+// kept, an unset mode drops them
+func (c *Config) KeepParagraphAnchors() bool {
+	return len(c.ParagraphAnchors) > 0 && c.ParagraphAnchors != ParagraphAnchorsNone
+}
+
+// GetOverride returns the PostOverride for the given HTML file name. If no
+// override is defined for the post, an empty PostOverride is returned.
+func (c *Config) GetOverride(htmlFileName string) *PostOverride {
+	if o, exists := c.Overrides[htmlFileName]; exists && o != nil {
+		return o
+	}
+
+	return &PostOverride{}
+}
+
+// loadOverrides reads the given JSON file in to a map of PostOverrides keyed
+// by the HTML file name of the posts
+//
+//	{
+//	  "2018-09-25_a-b-tests-developers-manual-f57f5c1a492.html": {
+//	    "featuredImage": 2,
+//	    "codeLanguages": {"0": "bash", "3": "go"}
+//	  }
+//	}
+func loadOverrides(f string) (map[string]*PostOverride, error) {
+	content, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[string]*PostOverride)
+	err = json.Unmarshal(content, &overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid overrides file: %s => %s", f, err)
+	}
+
+	return overrides, nil
+}
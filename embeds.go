@@ -0,0 +1,546 @@
+package main
+
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chamilad/html-to-markdown"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// An Embed represents an iframe embed found in a post
+type Embed struct {
+	// the url loaded by the iframe, unwrapped from embedly if needed
+	Src string
+	// the url of the embedded resource as given to embedly, empty for direct embeds
+	URL string
+	// the thumbnail of the embedded resource provided by embedly, if any
+	Image string
+	// dimensions of the iframe
+	Width, Height string
+}
+
+// An EmbedProvider knows how to render the embeds of a specific service
+type EmbedProvider struct {
+	Name string
+	// Match reports whether the given embed belongs to the provider
+	Match func(e *Embed) bool
+	// Render returns the markdown for the given embed, nil if the embed
+	// couldn't be rendered, in which case a link card will be rendered
+	Render func(e *Embed, conf *Config) *string
+}
+
+// embedProviders are tried in order against each iframe, and only the first
+// provider that matches gets to render it. Each one needs a Match that is
+// specific enough not to claim other services' embeds, and a Render that
+// returns nil for embeds it can't handle so that a link card is used instead.
+var embedProviders = []*EmbedProvider{
+	{
+		Name:  "youtube",
+		Match: matchHosts("youtube.com", "youtu.be", "youtube-nocookie.com"),
+		Render: func(e *Embed, conf *Config) *string {
+			id := youtubeVideoID(e.Src, e.URL)
+			if id == "" {
+				// playlists don't have a video id, but the youtube player can still be embedded as is
+				if isYoutubeEmbed(e.Src) {
+					return md.String(embedIframe(e.Src, e))
+				}
+
+				return nil
+			}
+
+			if conf.Target == TargetHugo {
+				return md.String(fmt.Sprintf("\n\n{{< youtube %s >}}\n\n", id))
+			}
+
+			return md.String(embedIframe(fmt.Sprintf("https://www.youtube.com/embed/%s", id), e))
+		},
+	},
+	{
+		Name:  "vimeo",
+		Match: matchHosts("vimeo.com"),
+		Render: func(e *Embed, conf *Config) *string {
+			id := vimeoVideoID(e.Src, e.URL)
+			if id == "" {
+				return nil
+			}
+
+			if conf.Target == TargetHugo {
+				return md.String(fmt.Sprintf("\n\n{{< vimeo %s >}}\n\n", id))
+			}
+
+			return md.String(embedIframe(fmt.Sprintf("https://player.vimeo.com/video/%s", id), e))
+		},
+	},
+	{
+		// <iframe src="https://www.slideshare.net/slideshow/embed_code/key/8br68UFQtb7qpF" width="600" height="500"
+		// frameborder="0" scrolling="no"></iframe>
+		Name:  "slideshare",
+		Match: matchHosts("slideshare.net"),
+		Render: func(e *Embed, conf *Config) *string {
+			if !strings.Contains(e.Src, "slideshare.net") {
+				return nil
+			}
+
+			return md.String(fmt.Sprintf(
+				"<iframe src=\"%s\" width=\"595\" height=\"485\" frameborder=\"0\" marginwidth=\"0\" "+
+					"marginheight=\"0\" scrolling=\"no\" style=\"border:1px solid #CCC; border-width:1px; "+
+					"margin-bottom:5px; \" allowfullscreen> </iframe>\n",
+				html.EscapeString(e.Src)))
+		},
+	},
+	{
+		// https://codepen.io/<user>/pen/<hash> => https://codepen.io/<user>/embed/<hash>
+		Name:  "codepen",
+		Match: matchHosts("codepen.io"),
+		Render: func(e *Embed, conf *Config) *string {
+			for _, raw := range []string{e.Src, e.URL} {
+				u, err := url.Parse(raw)
+				if err != nil || !strings.HasSuffix(u.Hostname(), "codepen.io") {
+					continue
+				}
+
+				pieces := strings.Split(strings.Trim(u.Path, "/"), "/")
+				if len(pieces) < 3 {
+					continue
+				}
+
+				src := fmt.Sprintf("https://codepen.io/%s/embed/%s?default-tab=result", pieces[0], pieces[2])
+				return md.String(embedIframe(src, e))
+			}
+
+			return nil
+		},
+	},
+	{
+		Name:  "soundcloud",
+		Match: matchHosts("soundcloud.com"),
+		Render: func(e *Embed, conf *Config) *string {
+			if strings.Contains(e.Src, "w.soundcloud.com/player") {
+				return md.String(embedIframe(e.Src, e))
+			}
+
+			if e.URL == "" {
+				return nil
+			}
+
+			src := fmt.Sprintf("https://w.soundcloud.com/player/?url=%s", url.QueryEscape(e.URL))
+			return md.String(embedIframe(src, e))
+		},
+	},
+	{
+		// https://open.spotify.com/<type>/<id> => https://open.spotify.com/embed/<type>/<id>
+		Name:  "spotify",
+		Match: matchHosts("spotify.com"),
+		Render: func(e *Embed, conf *Config) *string {
+			for _, raw := range []string{e.Src, e.URL} {
+				u, err := url.Parse(raw)
+				if err != nil || u.Hostname() != "open.spotify.com" {
+					continue
+				}
+
+				path := strings.Trim(u.Path, "/")
+				if !strings.HasPrefix(path, "embed/") {
+					path = "embed/" + path
+				}
+
+				return md.String(embedIframe(fmt.Sprintf("https://open.spotify.com/%s", path), e))
+			}
+
+			return nil
+		},
+	},
+	{
+		// https://www.instagram.com/p/<id>/
+		Name:  "instagram",
+		Match: matchHosts("instagram.com", "instagr.am"),
+		Render: func(e *Embed, conf *Config) *string {
+			for _, raw := range []string{e.URL, e.Src} {
+				m := instagramPostIDPattern.FindStringSubmatch(raw)
+				if len(m) < 2 {
+					continue
+				}
+
+				if conf.Target == TargetHugo {
+					return md.String(fmt.Sprintf("\n\n{{< instagram %s >}}\n\n", m[1]))
+				}
+
+				return md.String(embedIframe(fmt.Sprintf("https://www.instagram.com/p/%s/embed", m[1]), e))
+			}
+
+			return nil
+		},
+	},
+	{
+		// only the embed urls can be rendered in an iframe, other map links will be rendered as link cards
+		Name: "googlemaps",
+		Match: func(e *Embed) bool {
+			for _, raw := range []string{e.Src, e.URL} {
+				if strings.Contains(raw, "google.com/maps") || strings.Contains(raw, "goo.gl/maps/") {
+					return true
+				}
+			}
+
+			return matchHosts("maps.google.com", "maps.app.goo.gl")(e)
+		},
+		Render: func(e *Embed, conf *Config) *string {
+			if !strings.Contains(e.Src, "/maps/embed") {
+				return nil
+			}
+
+			return md.String(embedIframe(e.Src, e))
+		},
+	},
+}
+
+// instagramPostIDPattern matches the post id in instagram post urls
+var instagramPostIDPattern = regexp.MustCompile(`/p/([^/?#]+)`)
+
+// newEmbed creates an Embed from the given iframe element, nil if the iframe
+// doesn't have a src
+func newEmbed(iframe *goquery.Selection) *Embed {
+	src, exists := iframe.Attr("src")
+	if !exists || len(strings.TrimSpace(src)) == 0 {
+		return nil
+	}
+
+	e := &Embed{
+		Src:    src,
+		Width:  iframe.AttrOr("width", ""),
+		Height: iframe.AttrOr("height", ""),
+	}
+
+	// embedly wraps the actual embed url and the resource url in query params
+	u, err := url.Parse(src)
+	if err == nil && strings.HasSuffix(u.Hostname(), "embedly.com") {
+		q := u.Query()
+		e.Src = q.Get("src")
+		e.URL = q.Get("url")
+		e.Image = q.Get("image")
+	}
+
+	return e
+}
+
+// renderEmbed renders the given Embed using the first matching provider. Embedly
+// embeds of unknown providers are rendered as link cards since the embedly
+// widget doesn't work outside Medium. Returns nil for other unknown embeds.
+func renderEmbed(e *Embed, conf *Config) *string {
+	for _, p := range embedProviders {
+		if !p.Match(e) {
+			continue
+		}
+
+		if r := p.Render(e, conf); r != nil {
+			return r
+		}
+
+		break
+	}
+
+	if e.URL != "" {
+		return md.String(linkCard(e))
+	}
+
+	return nil
+}
+
+// matchHosts returns a matcher that checks if the src or url of an Embed is
+// hosted in any of the given domains or their subdomains
+func matchHosts(hosts ...string) func(e *Embed) bool {
+	return func(e *Embed) bool {
+		for _, raw := range []string{e.Src, e.URL} {
+			u, err := url.Parse(raw)
+			if err != nil {
+				continue
+			}
+
+			hostname := u.Hostname()
+			for _, h := range hosts {
+				if hostname == h || strings.HasSuffix(hostname, "."+h) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+}
+
+// youtubePlaylistEmbed is the path segment youtube uses in place of a video id
+// when embedding a playlist
+const youtubePlaylistEmbed = "videoseries"
+
+// youtubeVideoID extracts the video id from any of the given youtube urls, an empty string if none of them are
+// youtube video urls. Playlist urls, which only have a list param, are not video urls.
+//
+// https://www.youtube.com/embed/<id>?feature=oembed
+// https://www.youtube.com/watch?v=<id>
+// https://youtu.be/<id>
+func youtubeVideoID(urls ...string) string {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		host := strings.TrimPrefix(u.Hostname(), "www.")
+		switch host {
+		case "youtube.com", "m.youtube.com", "youtube-nocookie.com":
+			if v := u.Query().Get("v"); v != "" {
+				return v
+			}
+
+			if !strings.HasPrefix(u.Path, "/embed/") {
+				continue
+			}
+
+			if id := strings.Trim(strings.TrimPrefix(u.Path, "/embed/"), "/"); id != "" && id != youtubePlaylistEmbed {
+				return id
+			}
+		case "youtu.be":
+			if id := lastPathSegment(u.Path); id != "" {
+				return id
+			}
+		}
+	}
+
+	return ""
+}
+
+// isYoutubeEmbed reports whether the given url is loaded by the youtube
+// player, including playlist embeds
+func isYoutubeEmbed(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+	return (host == "youtube.com" || host == "youtube-nocookie.com") && strings.HasPrefix(u.Path, "/embed/")
+}
+
+// vimeo video ids are numeric
+var vimeoVideoIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+// vimeoVideoID extracts the video id from any of the given vimeo urls, an empty string if none of them are vimeo
+// video urls
+//
+// https://player.vimeo.com/video/<id>
+// https://vimeo.com/<id>
+func vimeoVideoID(urls ...string) string {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+
+		host := strings.TrimPrefix(u.Hostname(), "www.")
+		if host != "vimeo.com" && host != "player.vimeo.com" {
+			continue
+		}
+
+		if id := lastPathSegment(u.Path); vimeoVideoIDPattern.MatchString(id) {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// lastPathSegment returns the last non empty segment of the given url path
+func lastPathSegment(p string) string {
+	pieces := strings.Split(strings.Trim(p, "/"), "/")
+	return pieces[len(pieces)-1]
+}
+
+// embedIframe renders a plain HTML iframe for the given url, keeping the
+// dimensions of the original Embed if they are known
+func embedIframe(src string, e *Embed) string {
+	width, height := e.Width, e.Height
+	if width == "" || height == "" {
+		width, height = "560", "315"
+	}
+
+	return fmt.Sprintf(
+		"\n\n<iframe src=\"%s\" width=\"%s\" height=\"%s\" frameborder=\"0\" allowfullscreen></iframe>\n\n",
+		html.EscapeString(src),
+		html.EscapeString(width),
+		html.EscapeString(height))
+}
+
+// linkCard renders a link to the embedded resource, with the thumbnail if
+// embedly provided one
+func linkCard(e *Embed) string {
+	if e.Image != "" {
+		return fmt.Sprintf("\n\n[![%s](%s)](%s)\n[%s](%s)\n\n", e.URL, e.Image, e.URL, e.URL, e.URL)
+	}
+
+	return fmt.Sprintf("\n\n[%s](%s)\n\n", e.URL, e.URL)
+}
+
+// A Tweet represents a tweet embedded in a post
+type Tweet struct {
+	ID, URL string
+	// the text of the tweet, the author as "Name (@handle)" and the date as
+	// shown in the embed
+	Text, Author, Date string
+	// the text of the tweet with the links in it as markdown links
+	MarkdownText string
+}
+
+// newTweet creates a Tweet from the given twitter-tweet blockquote, nil if the
+// link to the tweet cannot be found
+//
+// <blockquote class="twitter-tweet"><p lang="en" dir="ltr">tweet text</p>&mdash; Author (@handle)
+// <a href="https://twitter.com/handle/status/1083326536512397312?ref_src=twsrc%5Etfw">January 10, 2019</a>
+// </blockquote>
+func newTweet(blockquote *goquery.Selection) *Tweet {
+	// the link to the tweet comes after any links in the tweet text
+	var link *goquery.Selection
+	blockquote.Find("a").Each(func(i int, a *goquery.Selection) {
+		if strings.Contains(a.AttrOr("href", ""), "/status/") {
+			link = a
+		}
+	})
+
+	if link == nil {
+		return nil
+	}
+
+	u, err := url.Parse(link.AttrOr("href", ""))
+	if err != nil {
+		return nil
+	}
+
+	// drop the tracking params
+	u.RawQuery = ""
+	t := &Tweet{
+		ID:   lastPathSegment(u.Path),
+		URL:  u.String(),
+		Date: strings.TrimSpace(link.Text()),
+	}
+
+	blockquote.Find("p").Each(func(i int, p *goquery.Selection) {
+		if i > 0 {
+			t.Text += "\n"
+			t.MarkdownText += "\n"
+		}
+
+		readCodeContent(p, &t.Text)
+		readTweetMarkdown(p, &t.MarkdownText)
+	})
+	t.Text = strings.TrimSpace(t.Text)
+	t.MarkdownText = strings.TrimSpace(t.MarkdownText)
+
+	// the author is the text directly inside the blockquote
+	blockquote.Contents().Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "#text" {
+			t.Author += s.Text()
+		}
+	})
+	t.Author = strings.TrimSpace(strings.Trim(strings.TrimSpace(t.Author), "—-"))
+
+	return t
+}
+
+// Embed renders the Tweet as an embed supported by the given output target
+func (t *Tweet) Embed(target string) string {
+	switch target {
+	case TargetJekyll:
+		// jekyll-twitter-plugin
+		return fmt.Sprintf("\n\n{%% twitter %s %%}\n\n", t.URL)
+	case TargetZola:
+		return fmt.Sprintf("\n\n{{ tweet(url=\"%s\") }}\n\n", t.URL)
+	case TargetHTML:
+		return fmt.Sprintf(
+			"\n\n<blockquote class=\"twitter-tweet\"><p>%s</p>&mdash; %s <a href=\"%s\">%s</a></blockquote>\n"+
+				"<script async src=\"https://platform.twitter.com/widgets.js\" charset=\"utf-8\"></script>\n\n",
+			strings.Replace(html.EscapeString(t.Text), "\n", "<br>", -1),
+			html.EscapeString(t.Author),
+			html.EscapeString(t.URL),
+			html.EscapeString(t.Date))
+	default:
+		return fmt.Sprintf("{{< tweet %s >}}", t.ID)
+	}
+}
+
+// StaticMarkdown renders the Tweet as a blockquote with the text, author and
+// date, which doesn't depend on twitter being available
+func (t *Tweet) StaticMarkdown() string {
+	quote := ""
+	for _, line := range strings.Split(t.MarkdownText, "\n") {
+		quote += strings.TrimSpace(fmt.Sprintf("> %s", line)) + "\n"
+	}
+
+	return fmt.Sprintf("\n\n%s>\n> — %s [%s](%s)\n\n", quote, t.Author, t.Date, t.URL)
+}
+
+// readTweetMarkdown reads the text of the given tweet paragraph, rendering the
+// links (mentions, hashtags and urls) as markdown links and honouring the br
+// tags. The read text will be appended to the string provided by the pointer
+func readTweetMarkdown(s *goquery.Selection, c *string) {
+	s.Contents().Each(func(i int, selection *goquery.Selection) {
+		switch goquery.NodeName(selection) {
+		case "br":
+			*c += "\n"
+		case "a":
+			*c += fmt.Sprintf("[%s](%s)", selection.Text(), selection.AttrOr("href", ""))
+		case "#text":
+			*c += selection.Text()
+		default:
+			readTweetMarkdown(selection, c)
+		}
+	})
+}
+
+// renderLinkCard renders the given link preview card placeholder as a link card
+// shortcode of the output target, or as an HTML block
+func renderLinkCard(card *goquery.Selection, conf *Config) string {
+	href := card.AttrOr("data-href", "")
+	title := card.AttrOr("data-title", "")
+	description := card.AttrOr("data-description", "")
+	image := card.Find("img").AttrOr("src", "")
+
+	if len(title) == 0 {
+		title = href
+	}
+
+	if conf.LinkCards == LinkCardsShortcode {
+		params := []string{
+			fmt.Sprintf("href=%s", quoteParam(href)),
+			fmt.Sprintf("title=%s", quoteParam(title)),
+			fmt.Sprintf("description=%s", quoteParam(description)),
+			fmt.Sprintf("image=%s", quoteParam(image)),
+		}
+
+		switch conf.Target {
+		case TargetHugo:
+			return fmt.Sprintf("\n\n{{< link-card %s >}}\n\n", strings.Join(params, " "))
+		case TargetJekyll:
+			return fmt.Sprintf("\n\n{%% include link-card.html %s %%}\n\n", strings.Join(params, " "))
+		case TargetZola:
+			return fmt.Sprintf("\n\n{{ link_card(%s) }}\n\n", strings.Join(params, ", "))
+		}
+	}
+
+	// html, or a target without shortcodes
+	cardHTML := fmt.Sprintf("<div class=\"link-card\"><a href=\"%s\">", html.EscapeString(href))
+	if len(image) > 0 {
+		cardHTML += fmt.Sprintf("<img src=\"%s\" alt=\"\">", html.EscapeString(image))
+	}
+
+	cardHTML += fmt.Sprintf("<strong>%s</strong>", html.EscapeString(title))
+	if len(description) > 0 {
+		cardHTML += fmt.Sprintf("<br><em>%s</em>", html.EscapeString(description))
+	}
+
+	return fmt.Sprintf("\n\n%s</a></div>\n\n", cardHTML)
+}
+
+// quoteParam quotes the given value to be used as a shortcode parameter
+func quoteParam(v string) string {
+	return fmt.Sprintf("\"%s\"", strings.Replace(v, "\"", "\\\"", -1))
+}
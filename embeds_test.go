@@ -0,0 +1,237 @@
+package main
+
+import (
+	"html"
+	"net/url"
+	"testing"
+)
+
+// embedlySrc wraps the given embed and resource urls the way Medium embeds
+// them through embedly
+func embedlySrc(src, resource, schema string) string {
+	q := url.Values{}
+	q.Set("src", src)
+	q.Set("url", resource)
+	q.Set("type", "text/html")
+	q.Set("schema", schema)
+
+	return "https://cdn.embedly.com/widgets/media.html?" + q.Encode()
+}
+
+func TestYoutubeVideoID(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want string
+	}{
+		{"watch", []string{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42"}, "dQw4w9WgXcQ"},
+		{"mobile watch", []string{"https://m.youtube.com/watch?v=dQw4w9WgXcQ"}, "dQw4w9WgXcQ"},
+		{"short link", []string{"https://youtu.be/dQw4w9WgXcQ"}, "dQw4w9WgXcQ"},
+		{"embed", []string{"https://www.youtube.com/embed/dQw4w9WgXcQ?feature=oembed"}, "dQw4w9WgXcQ"},
+		{"nocookie embed", []string{"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"}, "dQw4w9WgXcQ"},
+		{"playlist embed", []string{"https://www.youtube.com/embed/videoseries?list=PL123"}, ""},
+		{"playlist", []string{"https://www.youtube.com/playlist?list=PL123"}, ""},
+		{"embed without id", []string{"https://www.youtube.com/embed/?list=PL123"}, ""},
+		{"channel", []string{"https://www.youtube.com/channel/UC123"}, ""},
+		{
+			"playlist embed with video url",
+			[]string{"https://www.youtube.com/embed/videoseries?list=PL123", "https://www.youtube.com/watch?v=dQw4w9WgXcQ&list=PL123"},
+			"dQw4w9WgXcQ",
+		},
+		{"other host", []string{"https://vimeo.com/76979871"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := youtubeVideoID(tt.urls...); got != tt.want {
+				t.Errorf("youtubeVideoID(%q) = %q, want %q", tt.urls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVimeoVideoID(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want string
+	}{
+		{"player", []string{"https://player.vimeo.com/video/76979871?app_id=122963"}, "76979871"},
+		{"page", []string{"https://vimeo.com/76979871"}, "76979871"},
+		{"channel", []string{"https://vimeo.com/channels/staffpicks"}, ""},
+		{"other host", []string{"https://www.youtube.com/watch?v=dQw4w9WgXcQ"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vimeoVideoID(tt.urls...); got != tt.want {
+				t.Errorf("vimeoVideoID(%q) = %q, want %q", tt.urls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVideoEmbeds(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		hugo string
+		html string
+	}{
+		{
+			name: "youtube embedly",
+			src: embedlySrc(
+				"https://www.youtube.com/embed/dQw4w9WgXcQ?feature=oembed",
+				"http://www.youtube.com/watch?v=dQw4w9WgXcQ",
+				"youtube"),
+			hugo: "{{< youtube dQw4w9WgXcQ >}}",
+			html: `<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+		{
+			name: "youtube short link embedly",
+			src:  embedlySrc("", "https://youtu.be/dQw4w9WgXcQ", "youtube"),
+			hugo: "{{< youtube dQw4w9WgXcQ >}}",
+			html: `<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+		{
+			name: "youtube direct",
+			src:  "https://www.youtube.com/embed/dQw4w9WgXcQ",
+			hugo: "{{< youtube dQw4w9WgXcQ >}}",
+			html: `<iframe src="https://www.youtube.com/embed/dQw4w9WgXcQ" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+		{
+			name: "youtube playlist embedly",
+			src: embedlySrc(
+				"https://www.youtube.com/embed/videoseries?list=PL123",
+				"https://www.youtube.com/playlist?list=PL123",
+				"youtube"),
+			hugo: `<iframe src="https://www.youtube.com/embed/videoseries?list=PL123" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+			html: `<iframe src="https://www.youtube.com/embed/videoseries?list=PL123" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+		{
+			name: "youtube playlist link embedly",
+			src:  embedlySrc("", "https://www.youtube.com/playlist?list=PL123", "youtube"),
+			hugo: "[https://www.youtube.com/playlist?list=PL123](https://www.youtube.com/playlist?list=PL123)",
+			html: "[https://www.youtube.com/playlist?list=PL123](https://www.youtube.com/playlist?list=PL123)",
+		},
+		{
+			name: "vimeo embedly",
+			src: embedlySrc(
+				"https://player.vimeo.com/video/76979871?app_id=122963",
+				"https://vimeo.com/76979871",
+				"vimeo"),
+			hugo: "{{< vimeo 76979871 >}}",
+			html: `<iframe src="https://player.vimeo.com/video/76979871" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+		{
+			name: "vimeo direct",
+			src:  "https://player.vimeo.com/video/76979871",
+			hugo: "{{< vimeo 76979871 >}}",
+			html: `<iframe src="https://player.vimeo.com/video/76979871" width="640" height="480" frameborder="0" allowfullscreen></iframe>`,
+		},
+	}
+
+	for _, tt := range tests {
+		fragment := `<figure class="graf graf--figure graf--iframe"><iframe src="` + html.EscapeString(tt.src) +
+			`" width="640" height="480" frameborder="0" scrolling="no"></iframe></figure>`
+
+		for target, want := range map[string]string{TargetHugo: tt.hugo, TargetHTML: tt.html} {
+			t.Run(tt.name+"/"+target, func(t *testing.T) {
+				if got := convertHTML(t, Config{Target: target}, fragment); got != want {
+					t.Errorf("got:\n%s\nwant:\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEmbedIframeEscapesAttributes(t *testing.T) {
+	e := &Embed{Width: `640" onload="alert(1)`, Height: "480"}
+	got := embedIframe(`https://w.soundcloud.com/player/?url=a&auto_play="true"`, e)
+	want := "\n\n<iframe src=\"https://w.soundcloud.com/player/?url=a&amp;auto_play=&#34;true&#34;\" " +
+		"width=\"640&#34; onload=&#34;alert(1)\" height=\"480\" frameborder=\"0\" allowfullscreen></iframe>\n\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGoogleMapsMatch(t *testing.T) {
+	var maps *EmbedProvider
+	for _, p := range embedProviders {
+		if p.Name == "googlemaps" {
+			maps = p
+		}
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://www.google.com/maps/embed?pb=!1m18", true},
+		{"https://maps.google.com/?q=colombo", true},
+		{"https://maps.app.goo.gl/X1y2Z3", true},
+		{"https://goo.gl/maps/X1y2Z3", true},
+		{"https://goo.gl/X1y2Z3", false},
+		{"https://goo.gl/forms/X1y2Z3", false},
+		{"https://www.google.com/search?q=maps", false},
+	}
+
+	for _, tt := range tests {
+		if got := maps.Match(&Embed{URL: tt.url}); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestTweetRendering(t *testing.T) {
+	tweet := `<blockquote class="twitter-tweet"><p lang="en" dir="ltr">Released <a href="https://t.co/abc">github.com/m2h</a> with ` +
+		`<a href="https://twitter.com/hashtag/golang?src=hash">#golang</a><br>thanks <a href="https://twitter.com/gopher">@gopher</a></p>` +
+		`&mdash; Jane Doe (@jane) <a href="https://twitter.com/jane/status/1083326536512397312?ref_src=twsrc%5Etfw#a&b">January 10, 2019</a>` +
+		`</blockquote>`
+
+	tests := []struct {
+		name   string
+		target string
+		tweets string
+		want   string
+	}{
+		{
+			name:   "hugo",
+			target: TargetHugo,
+			tweets: TweetsEmbed,
+			want:   "{{< tweet 1083326536512397312 >}}",
+		},
+		{
+			name:   "jekyll",
+			target: TargetJekyll,
+			tweets: TweetsEmbed,
+			want:   "{% twitter https://twitter.com/jane/status/1083326536512397312#a&b %}",
+		},
+		{
+			name:   "html",
+			target: TargetHTML,
+			tweets: TweetsEmbed,
+			want: `<blockquote class="twitter-tweet"><p>Released github.com/m2h with #golang<br>thanks @gopher</p>` +
+				`&mdash; Jane Doe (@jane) <a href="https://twitter.com/jane/status/1083326536512397312#a&amp;b">January 10, 2019</a></blockquote>` +
+				"\n" + `<script async src="https://platform.twitter.com/widgets.js" charset="utf-8"></script>`,
+		},
+		{
+			name:   "static",
+			target: TargetHugo,
+			tweets: TweetsStatic,
+			want: "> Released [github.com/m2h](https://t.co/abc) with [#golang](https://twitter.com/hashtag/golang?src=hash)\n" +
+				"> thanks [@gopher](https://twitter.com/gopher)\n" +
+				">\n" +
+				"> — Jane Doe (@jane) [January 10, 2019](https://twitter.com/jane/status/1083326536512397312#a&b)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertHTML(t, Config{Target: tt.target, Tweets: tt.tweets}, tweet)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
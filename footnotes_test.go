@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestConvertFootnotes(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "superscript definition",
+			body: `<p>A claim<sup>1</sup>.</p><p>More text.</p><p><sup>1</sup> The source.</p>`,
+			want: "A claim[^1].\n\nMore text.\n\n[^1]: The source.",
+		},
+		{
+			name: "bracketed definition",
+			body: `<p>A claim<sup>[1]</sup>.</p><p>[1] The source.</p>`,
+			want: "A claim[^1].\n\n[^1]: The source.",
+		},
+		{
+			name: "numbered definition",
+			body: `<p>A claim<sup>1</sup> and another<sup>2</sup>.</p><p>1. The source.</p><p>2. Another source.</p>`,
+			want: "A claim[^1] and another[^2].\n\n[^1]: The source.\n\n[^2]: Another source.",
+		},
+		{
+			name: "parenthesis definition",
+			body: `<p>A claim<sup>1</sup>.</p><p>1) The source.</p>`,
+			want: "A claim[^1].\n\n[^1]: The source.",
+		},
+		{
+			name: "notes heading",
+			body: `<p>A claim<sup>1</sup>.</p><h3>Notes</h3><p>[1] The source.</p>`,
+			want: "A claim[^1].\n\n[^1]: The source.",
+		},
+		{
+			name: "other heading",
+			body: `<p>A claim<sup>1</sup>.</p><h3>Sources</h3><p>[1] The source.</p>`,
+			want: "A claim[^1].\n\n### Sources\n\n[^1]: The source.",
+		},
+		{
+			name: "exponent followed by a numbered paragraph",
+			body: `<p>The area is x<sup>2</sup>.</p><p>2. Multiply it by the height.</p><p>That gives the volume.</p>`,
+			want: "The area is x2.\n\n2. Multiply it by the height.\n\nThat gives the volume.",
+		},
+		{
+			name: "definition before the marker",
+			body: `<p>[1] Not a footnote.</p><p>A claim<sup>1</sup>.</p>`,
+			want: "[1] Not a footnote.\n\nA claim1.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(
+				`<section data-field="body">` + tt.body + `</section>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := &Post{DOM: dom}
+			p.ConvertFootnotes()
+
+			mgr := newTestManager(Config{Target: TargetHugo, ParagraphAnchors: ParagraphAnchorsNone})
+			got := strings.TrimSpace(mgr.MDConverter.Convert(dom.Find("body")))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
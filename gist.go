@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GistAPIURL is the Github API endpoint to read gists from
+var GistAPIURL = "https://api.github.com/gists"
+
+// GistRawURL is the host serving the raw content of gists
+var GistRawURL = "https://gist.githubusercontent.com"
+
+// GistFile represents a single file of a Github Gist
+type GistFile struct {
+	Filename  string `json:"filename"`
+	Language  string `json:"language"`
+	RawURL    string `json:"raw_url"`
+	Content   string `json:"content"`
+	Truncated bool   `json:"truncated"`
+}
+
+// fetchGistFiles reads the files of the gist embedded with the given script
+// src, sorted by the filename as shown by Github. If the src selects a
+// specific file with the file query param, only that file is returned.
+//
+// The Github API is used to list the files, which is rate limited for
+// anonymous requests. A token can be provided with the GITHUB_TOKEN
+// environment variable. If the API can't be reached, the raw content of the
+// gist is read, which only contains the first or the selected file.
+func fetchGistFiles(src string) ([]*GistFile, error) {
+	// https://gist.github.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a.js?file=deploy.sh
+	u, err := url.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	gistPath := strings.TrimSuffix(u.Path, ".js")
+	id := lastPathSegment(gistPath)
+	selected := u.Query().Get("file")
+	client := newHTTPClient()
+
+	files, err := fetchGistListing(client, id)
+	if err != nil {
+		f, err := fetchGistRaw(client, gistPath, selected)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*GistFile{f}, nil
+	}
+
+	result := make([]*GistFile, 0)
+	for _, f := range files {
+		if len(selected) > 0 && f.Filename != selected {
+			continue
+		}
+
+		// the api only returns the first MB of large files
+		if f.Truncated {
+			f.Content, err = httpGetString(client, f.RawURL)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(f.Content) == 0 {
+			continue
+		}
+
+		result = append(result, f)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no files found in gist: %s", src)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Filename < result[j].Filename
+	})
+
+	return result, nil
+}
+
+// fetchGistListing reads the files of the given gist id using the Github API
+func fetchGistListing(client *http.Client, id string) ([]*GistFile, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", GistAPIURL, id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	if token := os.Getenv("GITHUB_TOKEN"); len(token) > 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", token))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("couldn't read gist %s: %s", id, res.Status)
+	}
+
+	gist := struct {
+		Files map[string]*GistFile `json:"files"`
+	}{}
+
+	err = json.NewDecoder(res.Body).Decode(&gist)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*GistFile, 0, len(gist.Files))
+	for _, f := range gist.Files {
+		files = append(files, f)
+	}
+
+	return files, nil
+}
+
+// fetchGistRaw reads the raw content of the gist at the given path, selecting
+// the given file if not empty
+func fetchGistRaw(client *http.Client, gistPath, file string) (*GistFile, error) {
+	// https://gist.githubusercontent.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a/raw/deploy.sh
+	rawsrc := fmt.Sprintf("%s%s/raw", GistRawURL, gistPath)
+	if len(file) > 0 {
+		rawsrc = fmt.Sprintf("%s/%s", rawsrc, url.PathEscape(file))
+	}
+
+	content, err := httpGetString(client, rawsrc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) == 0 {
+		return nil, fmt.Errorf("empty gist: %s", gistPath)
+	}
+
+	return &GistFile{
+		Filename: file,
+		RawURL:   rawsrc,
+		Content:  content,
+	}, nil
+}
+
+// CodeLanguage determines the code block language of the GistFile. The file
+// name is looked up in the given language overrides and the known file
+// extensions first, then the language reported by Github is used, and
+// finally the content is inspected.
+func (f *GistFile) CodeLanguage(overrides map[string]string) string {
+	if lang, known := languageFromFilename(f.Filename, overrides); known {
+		return lang
+	}
+
+	if lang := gistLanguage(f.Language); len(lang) > 0 {
+		return lang
+	}
+
+	return guessLanguage(f.Content)
+}
+
+// gistLanguage converts the language reported by Github to a code block
+// language. Certain languages don't translate well to markdown.
+func gistLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	switch lang {
+	case "text", "unknown":
+		return ""
+	case "shell":
+		return "bash"
+	default:
+		return strings.Replace(lang, " ", "-", -1)
+	}
+}
+
+// httpGetString reads the body of the given url as a string
+func httpGetString(client *http.Client, u string) (string, error) {
+	res, err := client.Get(u)
+	if err != nil {
+		return "", err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("couldn't read %s: %s", u, res.Status)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	return string(body), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// stubGists serves the given gist files from the gist api and the raw gist
+// host for the duration of the test. Gists missing from the api are only
+// served raw.
+func stubGists(t *testing.T, api map[string][]*GistFile, raw map[string]string) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/gists/") {
+			files, ok := api[strings.TrimPrefix(r.URL.Path, "/gists/")]
+			if !ok {
+				http.Error(w, "rate limited", http.StatusForbidden)
+				return
+			}
+
+			gist := struct {
+				Files map[string]*GistFile `json:"files"`
+			}{Files: map[string]*GistFile{}}
+			for _, f := range files {
+				gist.Files[f.Filename] = f
+			}
+
+			_ = json.NewEncoder(w).Encode(gist)
+			return
+		}
+
+		content, ok := raw[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		fmt.Fprint(w, content)
+	}))
+
+	apiURL, rawURL := GistAPIURL, GistRawURL
+	GistAPIURL, GistRawURL = srv.URL+"/gists", srv.URL
+	t.Cleanup(func() {
+		GistAPIURL, GistRawURL = apiURL, rawURL
+		srv.Close()
+	})
+}
+
+func TestGistEmbed(t *testing.T) {
+	stubGists(t,
+		map[string][]*GistFile{
+			"single": {
+				{Filename: "hello.go", Language: "Go", Content: "package main"},
+			},
+			"multi": {
+				{Filename: "run.sh", Language: "Shell", Content: "echo hi"},
+				{Filename: "notes.txt", Language: "Text", Content: "some notes"},
+			},
+		},
+		map[string]string{
+			"/u/fallback/raw":        "first file",
+			"/u/fallback/raw/app.py": "print(1)",
+		})
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "single file",
+			src:  "https://gist.github.com/u/single.js",
+			want: "`hello.go`\n\n```go\npackage main\n```",
+		},
+		{
+			name: "multiple files",
+			src:  "https://gist.github.com/u/multi.js",
+			want: "`notes.txt`\n\n```\nsome notes\n```\n\n`run.sh`\n\n```bash\necho hi\n```",
+		},
+		{
+			name: "selected file",
+			src:  "https://gist.github.com/u/multi.js?file=run.sh",
+			want: "`run.sh`\n\n```bash\necho hi\n```",
+		},
+		{
+			name: "raw fallback",
+			src:  "https://gist.github.com/u/fallback.js",
+			want: "```\nfirst file\n```",
+		},
+		{
+			name: "raw fallback with selected file",
+			src:  "https://gist.github.com/u/fallback.js?file=app.py",
+			want: "`app.py`\n\n```python\nprint(1)\n```",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertHTML(t, Config{}, fmt.Sprintf(`<figure><script src="%s"></script></figure>`, tt.src))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGistFileCodeLanguage(t *testing.T) {
+	overrides := map[string]string{
+		".sh":       "shell",
+		".conf":     "nginx",
+		"build.sh":  "console",
+		"caddyfile": "caddy",
+	}
+
+	tests := []struct {
+		name      string
+		file      GistFile
+		overrides map[string]string
+		want      string
+	}{
+		{"extension over api language", GistFile{Filename: "app.py", Language: "Text"}, nil, "python"},
+		{"file name", GistFile{Filename: "Dockerfile", Language: "Text"}, nil, "dockerfile"},
+		{"extension mapped to no language", GistFile{Filename: "notes.txt", Language: "Markdown"}, nil, ""},
+		{"api language for unknown extension", GistFile{Filename: "site.conf", Language: "Nginx"}, nil, "nginx"},
+		{"api language with spaces", GistFile{Filename: "a.unknown", Language: "Protocol Buffer"}, nil, "protocol-buffer"},
+		{"api shell language", GistFile{Filename: "script", Language: "Shell"}, nil, "bash"},
+		{"content", GistFile{Filename: "script", Language: "Text", Content: "#!/usr/bin/env python3\nprint(1)"}, nil, "python"},
+		{"unknown", GistFile{Filename: "script", Language: "Unknown", Content: "hello"}, nil, ""},
+		{"override extension over known extension", GistFile{Filename: "run.sh", Language: "Shell"}, overrides, "shell"},
+		{"override file name over override extension", GistFile{Filename: "build.sh"}, overrides, "console"},
+		{"override over api language", GistFile{Filename: "site.conf", Language: "Text"}, overrides, "nginx"},
+		{"override file name is case insensitive", GistFile{Filename: "Caddyfile"}, overrides, "caddy"},
+		{"known extension without matching override", GistFile{Filename: "main.go"}, overrides, "go"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.file.CodeLanguage(tt.overrides); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGistLanguage(t *testing.T) {
+	tests := map[string]string{
+		"Go":              "go",
+		"Shell":           "bash",
+		"Text":            "",
+		"Unknown":         "",
+		"":                "",
+		" Python ":        "python",
+		"Protocol Buffer": "protocol-buffer",
+	}
+
+	for lang, want := range tests {
+		if got := gistLanguage(lang); got != want {
+			t.Errorf("gistLanguage(%q): got %q, want %q", lang, got, want)
+		}
+	}
+}
@@ -1,10 +1,26 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+)
 
 // Image represents details of an img element in an HTML document
 type Image struct {
 	MediumURL, FileName string
+
+	// position of the img element in the post and its dimensions as
+	// reported by Medium
+	Index, Width, Height int
+
+	// whether Medium marked the image as the featured image of the post
+	Featured bool
+
+	// whether the image is the thumbnail of a link preview card
+	Thumbnail bool
+
+	// the img element in the post DOM
+	element *goquery.Selection
 }
 
 // GetHugoSource returns the value to be used for a given image. This value
@@ -12,3 +28,24 @@ type Image struct {
 func (i *Image) GetHugoSource() string {
 	return fmt.Sprintf("/%s/%s/%s", HContentType, HImagesDirName, i.FileName)
 }
+
+// Area returns the pixel area of the image, 0 if the dimensions are unknown
+func (i *Image) Area() int {
+	return i.Width * i.Height
+}
+
+// RemoveFromBody removes the image from the post DOM, along with the enclosing
+// figure and its caption if there is one
+func (i *Image) RemoveFromBody() {
+	if i.element == nil {
+		return
+	}
+
+	figure := i.element.Closest("figure")
+	if figure.Length() > 0 {
+		figure.Remove()
+		return
+	}
+
+	i.element.Remove()
+}
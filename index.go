@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// MediumShortLinkHost is the host of the Medium short links, which redirect to
+// the Medium posts
+const MediumShortLinkHost = "link.medium.com"
+
+// A PostIndex maps the Medium post ids of the converted posts to their paths
+// on the new site, so that the links between the posts can be rewritten
+// regardless of the domain or publication they point to
+type PostIndex struct {
+	posts map[string]*IndexedPost
+
+	// the Medium urls the short links were resolved to
+	shortLinks map[string]string
+	client     *http.Client
+}
+
+// newPostIndex creates an empty PostIndex
+func newPostIndex() *PostIndex {
+	return &PostIndex{
+		posts:      make(map[string]*IndexedPost),
+		shortLinks: make(map[string]string),
+		client:     newHTTPClient(),
+	}
+}
+
+// An IndexedPost holds the details of a converted post needed to link to it
+type IndexedPost struct {
+	// the path of the post on the new site
+	Path string
+
+	// the markdown file name of the post
+	MdFilename string
+}
+
+// Add records the given converted post for the Medium post with the given id
+func (idx *PostIndex) Add(id string, post *IndexedPost) {
+	idx.posts[id] = post
+}
+
+// Len returns the number of posts in the index
+func (idx *PostIndex) Len() int {
+	return len(idx.posts)
+}
+
+// Lookup returns the Medium post id and the details of the converted post the
+// given link points to. The link can be to any domain, it's matched by the
+// trailing post id of the path. Medium short links are resolved first. The
+// last return value reports whether the link points to an indexed post.
+func (idx *PostIndex) Lookup(link string) (string, *IndexedPost, bool) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "", nil, false
+	}
+
+	if u.Hostname() == MediumShortLinkHost {
+		link = idx.resolveShortLink(link)
+	}
+
+	id := mediumPostID(link)
+	post, exists := idx.posts[id]
+	if len(id) == 0 || !exists {
+		return "", nil, false
+	}
+
+	return id, post, true
+}
+
+// resolveShortLink follows the redirects of the given Medium short link and
+// returns the url it points to, the short link itself if it can't be resolved
+func (idx *PostIndex) resolveShortLink(link string) string {
+	if resolved, exists := idx.shortLinks[link]; exists {
+		return resolved
+	}
+
+	resolved := link
+	res, err := idx.client.Get(link)
+	if err == nil {
+		res.Body.Close()
+
+		// the url of the last request made while following the redirects
+		if res.StatusCode == http.StatusOK && !strings.EqualFold(res.Request.URL.Hostname(), MediumShortLinkHost) {
+			resolved = res.Request.URL.String()
+		}
+	}
+
+	idx.shortLinks[link] = resolved
+	return resolved
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+// rewriteTransport sends every request to the given test server, keeping the
+// original url in the response so that the redirects look like they were
+// followed on the original hosts
+type rewriteTransport struct {
+	server *url.URL
+}
+
+func (rt *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme, out.URL.Host = rt.server.Scheme, rt.server.Host
+	out.Host = req.URL.Host
+
+	res, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Request = req
+	return res, nil
+}
+
+func TestPostIndexLookup(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Host + r.URL.Path {
+		case "link.medium.com/AbCdEf":
+			http.Redirect(w, r, "https://medium.com/@author/a-b-tests-f57f5c1a492?source=link", http.StatusMovedPermanently)
+		case "link.medium.com/broken":
+			http.Redirect(w, r, "https://link.medium.com/missing", http.StatusFound)
+		case "medium.com/@author/a-b-tests-f57f5c1a492":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	server, _ := url.Parse(srv.URL)
+	idx := newPostIndex()
+	idx.client = &http.Client{Transport: &rewriteTransport{server: server}}
+	idx.Add("f57f5c1a492", &IndexedPost{Path: "/post/2018-09-25_a-b-tests/", MdFilename: "2018-09-25_a-b-tests.md"})
+
+	tests := []struct {
+		name  string
+		link  string
+		found bool
+	}{
+		{"medium", "https://medium.com/@author/a-b-tests-f57f5c1a492", true},
+		{"renamed post", "https://medium.com/@author/old-title-f57f5c1a492", true},
+		{"custom domain", "https://blog.example.com/a-b-tests-f57f5c1a492?source=rss", true},
+		{"publication", "https://medium.com/some-publication/a-b-tests-f57f5c1a492#3f51", true},
+		{"id only", "https://medium.com/p/f57f5c1a492", true},
+		{"not indexed", "https://medium.com/@author/another-post-1a2b3c4d5e6f", false},
+		{"no id", "https://example.com/about", false},
+		{"short link", "https://link.medium.com/AbCdEf", true},
+		{"unresolved short link", "https://link.medium.com/broken", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, post, found := idx.Lookup(tt.link)
+			if found != tt.found {
+				t.Fatalf("got found %t, want %t", found, tt.found)
+			}
+
+			if found && (id != "f57f5c1a492" || post.Path != "/post/2018-09-25_a-b-tests/") {
+				t.Errorf("got %s => %s", id, post.Path)
+			}
+		})
+	}
+
+	// short links are resolved once
+	srv.Close()
+	if _, _, found := idx.Lookup("https://link.medium.com/AbCdEf"); !found {
+		t.Errorf("the resolved short link was not reused")
+	}
+}
+
+func TestIndexPosts(t *testing.T) {
+	dir := t.TempDir()
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "sections.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"2019-01-10_Sections-1a2b3c4d5e6f.html": string(content),
+		"2019-01-11_untitled-2b3c4d5e6f7a.html": `<html><head><title></title></head><body><footer>` +
+			`<a href="https://medium.com/@chamilad/2b3c4d5e6f7a" class="p-canonical">Canonical link</a></footer></body></html>`,
+		"notes.txt": "not a post",
+	}
+
+	for name, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(c), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := newTestManager(Config{Permalink: "/posts/{{ .Slug }}/"})
+	mgr.MediumPostsPath = dir
+	mgr.IndexPosts(infos)
+
+	if mgr.PostIndex.Len() != 1 {
+		t.Errorf("got %d indexed posts, want only the titled post", mgr.PostIndex.Len())
+	}
+
+	_, post, found := mgr.PostIndex.Lookup("https://example.com/renamed-1a2b3c4d5e6f")
+	if !found {
+		t.Fatalf("the titled post was not indexed")
+	}
+
+	if post.Path != "/posts/sections/" || post.MdFilename != "2019-01-10_sections.md" {
+		t.Errorf("got %q => %q, want /posts/sections/ => 2019-01-10_sections.md", post.Path, post.MdFilename)
+	}
+}
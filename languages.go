@@ -0,0 +1,345 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// extensionLanguages maps file extensions and well known file names (in lower
+// case) to code block languages. Can be extended or overridden with the
+// languages file.
+var extensionLanguages = map[string]string{
+	".bash":       "bash",
+	".c":          "c",
+	".cc":         "cpp",
+	".clj":        "clojure",
+	".cpp":        "cpp",
+	".cs":         "csharp",
+	".css":        "css",
+	".dart":       "dart",
+	".diff":       "diff",
+	".erl":        "erlang",
+	".ex":         "elixir",
+	".exs":        "elixir",
+	".go":         "go",
+	".gradle":     "groovy",
+	".graphql":    "graphql",
+	".groovy":     "groovy",
+	".h":          "c",
+	".hcl":        "hcl",
+	".hpp":        "cpp",
+	".hs":         "haskell",
+	".html":       "html",
+	".ini":        "ini",
+	".java":       "java",
+	".js":         "javascript",
+	".json":       "json",
+	".jsx":        "jsx",
+	".kt":         "kotlin",
+	".lua":        "lua",
+	".md":         "markdown",
+	".patch":      "diff",
+	".php":        "php",
+	".pl":         "perl",
+	".properties": "properties",
+	".proto":      "protobuf",
+	".ps1":        "powershell",
+	".py":         "python",
+	".r":          "r",
+	".rb":         "ruby",
+	".rs":         "rust",
+	".scala":      "scala",
+	".scss":       "scss",
+	".sh":         "bash",
+	".sql":        "sql",
+	".swift":      "swift",
+	".tf":         "hcl",
+	".toml":       "toml",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".txt":        "",
+	".vim":        "vim",
+	".xml":        "xml",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".zsh":        "bash",
+	"dockerfile":  "dockerfile",
+	"jenkinsfile": "groovy",
+	"makefile":    "makefile",
+	"vagrantfile": "ruby",
+}
+
+// shebangLanguages maps interpreters found in shebang lines to code block
+// languages
+var shebangLanguages = map[string]string{
+	"bash":    "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"python":  "python",
+	"python3": "python",
+	"node":    "javascript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+}
+
+// languageFromFilename returns the code block language for the given file
+// name based on the file name or the extension, using the given overrides
+// before the known languages. The second return value reports whether the
+// language is known.
+func languageFromFilename(name string, overrides map[string]string) (string, bool) {
+	keys := []string{strings.ToLower(name), strings.ToLower(filepath.Ext(name))}
+	for _, table := range []map[string]string{overrides, extensionLanguages} {
+		for _, k := range keys {
+			if len(k) == 0 {
+				continue
+			}
+
+			if lang, exists := table[k]; exists {
+				return lang, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// LanguageConfidenceThreshold is the confidence below which an inferred code
+// block language is only reported as a low confidence guess, and not used
+const LanguageConfidenceThreshold = 0.6
+
+// A languageSignal is a pattern that hints the code is written in a certain
+// language, weighted by how specific the pattern is to the language
+type languageSignal struct {
+	pattern *regexp.Regexp
+	weight  int
+}
+
+// signal compiles a multiline languageSignal
+func signal(pattern string, weight int) languageSignal {
+	return languageSignal{regexp.MustCompile(`(?m)` + pattern), weight}
+}
+
+// languageSignals lists the keyword and syntax patterns used to classify code
+var languageSignals = map[string][]languageSignal{
+	"go": {
+		signal(`^package \w+\s*$`, 3),
+		signal(`\bfunc\s+(\(\w+ \*?\w+\)\s*)?\w+\(`, 3),
+		signal(`\berr != nil\b`, 3),
+		signal(`\bfmt\.\w+\(`, 2),
+		signal(`\w+ := `, 1),
+	},
+	"python": {
+		signal(`^\s*def \w+\(.*\):\s*$`, 3),
+		signal(`^\s*(from [\w.]+ )?import [\w.]+(\s+as \w+)?\s*$`, 1),
+		signal(`^\s*(if|elif|for|while|with|try|except|else|class)\b.*:\s*$`, 1),
+		signal(`\bself\.\w+`, 2),
+		signal(`__name__|__init__`, 3),
+		signal(`\bprint\(`, 1),
+	},
+	"javascript": {
+		signal(`\b(const|let|var) \w+ = `, 1),
+		signal(`\bfunction\s*\w*\s*\(`, 2),
+		signal(`\bconsole\.log\(`, 3),
+		signal(`\brequire\(['"]`, 2),
+		signal(`\bdocument\.\w+`, 2),
+		signal(`===|!==|=> \{`, 2),
+	},
+	"java": {
+		signal(`\bpublic (static )?(final )?(class|void|interface)\b`, 3),
+		signal(`\bSystem\.out\.print`, 3),
+		signal(`^\s*@Override\s*$`, 3),
+		signal(`^import java\.`, 3),
+		signal(`\b(private|protected) \w+(<[\w, ]+>)? \w+;`, 2),
+	},
+	"csharp": {
+		signal(`^using System`, 3),
+		signal(`\bConsole\.Write(Line)?\(`, 3),
+		signal(`^\s*namespace [\w.]+`, 2),
+	},
+	"cpp": {
+		signal(`^#include\s*[<"]`, 3),
+		signal(`\bstd::\w+`, 3),
+		signal(`\bcout\s*<<`, 3),
+	},
+	"rust": {
+		signal(`\bfn \w+\(`, 2),
+		signal(`\blet mut\b`, 3),
+		signal(`\bprintln!\(`, 3),
+		signal(`^use \w+::`, 2),
+	},
+	"ruby": {
+		signal(`^\s*def \w+(\(.*\))?\s*$`, 2),
+		signal(`^\s*end\s*$`, 2),
+		signal(`\bputs\b`, 2),
+		signal(`\.each do\b`, 3),
+		signal(`^require '`, 2),
+	},
+	"bash": {
+		signal(`^\s*\$ \w+`, 2),
+		signal(`^\s*(sudo|apt-get|apt|yum|brew|cd|ls|mkdir|export|echo|curl|wget|docker|kubectl|git|make|chmod|tar|ssh|systemctl|npm|pip) `, 2),
+		signal(`\|\s*(grep|awk|sed|xargs|tee)\b`, 2),
+		signal(`^\s*(if \[|fi|then|done|esac)\b`, 2),
+		signal(`\$\{\w+\}`, 1),
+	},
+	"sql": {
+		signal(`(?i)\bselect\b.+\bfrom\b`, 3),
+		signal(`(?i)\b(insert into|create table|alter table|delete from|update \w+ set)\b`, 3),
+		signal(`(?i)\b(where|join|group by|order by)\b`, 1),
+	},
+	"yaml": {
+		signal(`^\s*[\w.-]+:\s*$`, 2),
+		signal(`^\s*- [\w.-]+: `, 2),
+		signal(`^\s*[\w.-]+: [^;{}()\n]+$`, 1),
+		signal(`^---\s*$`, 1),
+	},
+	"html": {
+		signal(`(?i)<!DOCTYPE html>`, 3),
+		signal(`<(html|head|body|div|span|script|p|a href)\b`, 2),
+	},
+	"xml": {
+		signal(`</[\w:.-]+>`, 1),
+		signal(`<[\w:.-]+( [\w:.-]+="[^"]*")*\s*/?>`, 1),
+	},
+	"css": {
+		signal(`^\s*[.#]?[\w-]+( [.#]?[\w-]+)*\s*\{\s*$`, 1),
+		signal(`^\s*[\w-]+:\s*[^;]+;\s*$`, 2),
+	},
+	"dockerfile": {
+		signal(`^(FROM|RUN|CMD|COPY|ADD|ENTRYPOINT|ENV|EXPOSE|WORKDIR) `, 2),
+	},
+	"hcl": {
+		signal(`^\s*(resource|variable|provider|module|output|data) "`, 3),
+	},
+}
+
+// minSignalMatches is the number of signal matches a language needs before it
+// is scored at all. Prose such as "Note: this is a quote" looks like a YAML
+// key and value on its own.
+var minSignalMatches = map[string]int{
+	"yaml": 2,
+}
+
+// guessLanguage tries to determine the language of the given code from its
+// content. Returns an empty string if the language can't be determined with
+// enough confidence.
+func guessLanguage(code string) string {
+	lang, confidence := classifyLanguage(code)
+	if confidence < LanguageConfidenceThreshold {
+		return ""
+	}
+
+	return lang
+}
+
+// classifyLanguage determines the most likely language of the given code and
+// the confidence of the classification, between 0 and 1. Well known markers
+// such as shebang lines are trusted fully, otherwise the keyword and syntax
+// signals of each language are scored. Returns an empty string if no language
+// could be matched.
+func classifyLanguage(code string) (string, float64) {
+	code = strings.TrimSpace(code)
+
+	if lang := languageFromMarkers(code); len(lang) > 0 {
+		return lang, 1
+	}
+
+	best, bestScore, total := "", 0, 0
+	for lang, signals := range languageSignals {
+		score, count := 0, 0
+		for _, s := range signals {
+			// avoid a single repetitive pattern dominating the score
+			matches := len(s.pattern.FindAllStringIndex(code, 3))
+			score += matches * s.weight
+			count += matches
+		}
+
+		if count < minSignalMatches[lang] {
+			score = 0
+		}
+
+		total += score
+		if score > bestScore || (score == bestScore && score > 0 && lang < best) {
+			best, bestScore = lang, score
+		}
+	}
+
+	if bestScore == 0 {
+		return "", 0
+	}
+
+	// the share of the best language, scaled down if there's little evidence
+	confidence := float64(bestScore) / float64(total)
+	if bestScore < 6 {
+		confidence *= float64(bestScore) / 6
+	}
+
+	return best, confidence
+}
+
+// kubernetesManifestPattern matches the apiVersion key that starts Kubernetes
+// and similar YAML manifests
+var kubernetesManifestPattern = regexp.MustCompile(`(?m)^apiVersion: `)
+
+// languageFromMarkers checks for markers that identify the language of the
+// given code with certainty, such as shebang lines
+func languageFromMarkers(code string) string {
+	// #!/usr/bin/env python3, #!/bin/bash
+	if strings.HasPrefix(code, "#!") {
+		shebang := strings.Fields(strings.TrimPrefix(strings.SplitN(code, "\n", 2)[0], "#!"))
+		if len(shebang) == 0 {
+			return ""
+		}
+
+		interpreter := filepath.Base(shebang[0])
+		if interpreter == "env" && len(shebang) > 1 {
+			interpreter = shebang[1]
+		}
+
+		return shebangLanguages[interpreter]
+	}
+
+	switch {
+	case strings.HasPrefix(code, "<?php"):
+		return "php"
+	case strings.HasPrefix(code, "<?xml"):
+		return "xml"
+	case (strings.HasPrefix(code, "{") || strings.HasPrefix(code, "[")) && json.Valid([]byte(code)):
+		return "json"
+	case kubernetesManifestPattern.MatchString(code):
+		return "yaml"
+	}
+
+	return ""
+}
+
+// loadLanguages reads the given JSON file in to a map of file extensions or
+// file names to code block languages
+//
+//	{
+//	  ".conf": "nginx",
+//	  "Caddyfile": "caddy"
+//	}
+func loadLanguages(f string) (map[string]string, error) {
+	content, err := ioutil.ReadFile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	languages := make(map[string]string)
+	err = json.Unmarshal(content, &languages)
+	if err != nil {
+		return nil, fmt.Errorf("invalid languages file: %s => %s", f, err)
+	}
+
+	// lookups are case insensitive
+	result := make(map[string]string)
+	for k, v := range languages {
+		result[strings.ToLower(k)] = v
+	}
+
+	return result, nil
+}
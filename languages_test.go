@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestClassifyLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"shebang", "#!/usr/bin/env python3\nprint('hi')", "python"},
+		{"json", `{"name": "m2h", "version": 1}`, "json"},
+		{"kubernetes", "apiVersion: v1\nkind: Pod", "yaml"},
+		{
+			"yaml",
+			"server:\n  port: 8080\n  host: localhost\ndatabase:\n  - name: users",
+			"yaml",
+		},
+		{
+			"go",
+			"package main\n\nfunc main() {\n\tif err != nil {\n\t\tfmt.Println(err)\n\t}\n}",
+			"go",
+		},
+		{"prose", "Note: this is a quote", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := classifyLanguage(tt.code); got != tt.want {
+				t.Errorf("classifyLanguage(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotateCodeLanguagesLowConfidence(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(
+		"<pre>Note: this is a quote\nTip: so is this</pre>" +
+			"<p>text</p>" +
+			"<pre>server:\n  port: 8080\n  host: localhost\ndatabase:\n  - name: users</pre>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom}
+	p.AnnotateCodeLanguages(&PostOverride{}, true)
+
+	pres := dom.Find("pre")
+	if lang, exists := pres.Eq(0).Attr(CodeLanguageAttr); exists {
+		t.Errorf("low confidence guess %q was set on the code block", lang)
+	}
+
+	if lang := pres.Eq(1).AttrOr(CodeLanguageAttr, ""); lang != "yaml" {
+		t.Errorf("got language %q, want yaml", lang)
+	}
+
+	if len(p.UncertainLanguages) != 1 || p.UncertainLanguages[0].Block != 0 {
+		t.Fatalf("got uncertain languages %v, want only block 0", p.UncertainLanguages)
+	}
+
+	if g := p.UncertainLanguages[0]; g.Language != "yaml" || g.Confidence >= LanguageConfidenceThreshold {
+		t.Errorf("got guess %s (%.2f), want a low confidence yaml guess", g.Language, g.Confidence)
+	}
+}
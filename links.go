@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultStripParams are the query params removed from the links by default,
+// the Medium referral and friend link params and the Google Analytics params.
+// The Medium params are only removed from the links to Medium.
+const DefaultStripParams = "source,utm_*,sk"
+
+// NormalizedLinksFileName is the name of the file the normalized links are
+// logged to, in the output directory
+const NormalizedLinksFileName = "normalized-links.txt"
+
+// mediumParams are the params Medium adds to the links of the posts, which are
+// only removed from the links to Medium, the custom domains of the posts and
+// the targets of the Medium redirect wrappers. Other sites may use them for
+// something else.
+var mediumParams = map[string]bool{
+	"source": true,
+	"sk":     true,
+}
+
+// normalizeURL removes the query params matching the given patterns from the
+// given url, and resolves the Medium redirect wrappers to their target. The
+// Medium params are only removed if the url is on Medium or one of the given
+// custom domains, or was wrapped by Medium. The url is returned as is if
+// nothing was changed, or it can't be parsed.
+//
+// https://medium.com/r/?url=https%3A%2F%2Fexample.com%3Futm_source%3Dmedium => https://example.com
+func normalizeURL(raw string, patterns []string, customHosts []string) string {
+	return normalizeLink(raw, patterns, customHosts, false)
+}
+
+// normalizeLink normalizes the given url as described in normalizeURL,
+// unwrapped reports whether the url was the target of a redirect wrapper
+func normalizeLink(raw string, patterns []string, customHosts []string, unwrapped bool) string {
+	u, err := url.Parse(raw)
+	if err != nil || len(u.Host) == 0 {
+		return raw
+	}
+
+	// https://medium.com/r/?url=https%3A%2F%2Fexample.com
+	if isMediumHost(u.Hostname()) && strings.TrimSuffix(u.Path, "/") == "/r" {
+		if target := u.Query().Get("url"); len(target) > 0 && target != raw {
+			return normalizeLink(target, patterns, customHosts, true)
+		}
+	}
+
+	if len(u.RawQuery) == 0 {
+		return raw
+	}
+
+	medium := unwrapped || isMediumHost(u.Hostname())
+	for _, h := range customHosts {
+		medium = medium || u.Hostname() == h
+	}
+
+	// the params are filtered in place to keep the order of the rest
+	kept := make([]string, 0)
+	for _, param := range strings.Split(u.RawQuery, "&") {
+		name, err := url.QueryUnescape(strings.SplitN(param, "=", 2)[0])
+		if err != nil || !matchesParam(name, patterns) || (mediumParams[name] && !medium) {
+			kept = append(kept, param)
+		}
+	}
+
+	if len(kept) == len(strings.Split(u.RawQuery, "&")) {
+		return raw
+	}
+
+	u.RawQuery = strings.Join(kept, "&")
+	return u.String()
+}
+
+// matchesParam reports whether the given query param name matches any of the
+// given patterns, utm_* matches all the params starting with utm_
+func matchesParam(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if matched, _ := path.Match(p, name); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isMediumHost reports whether the given host name belongs to Medium
+func isMediumHost(hostname string) bool {
+	return hostname == "medium.com" || strings.HasSuffix(hostname, ".medium.com")
+}
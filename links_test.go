@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	patterns := splitList(DefaultStripParams)
+	customHosts := []string{"coder.today"}
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{
+			"medium",
+			"https://medium.com/@chamilad/a-post-1a2b3c4d5e6f?source=friends_link&sk=abc123",
+			"https://medium.com/@chamilad/a-post-1a2b3c4d5e6f",
+		},
+		{
+			"medium publication",
+			"https://blog.medium.com/a-post-1a2b3c4d5e6f?source=collection_home---4",
+			"https://blog.medium.com/a-post-1a2b3c4d5e6f",
+		},
+		{
+			"custom domain",
+			"https://coder.today/a-post-1a2b3c4d5e6f?source=rss----1",
+			"https://coder.today/a-post-1a2b3c4d5e6f",
+		},
+		{
+			"other site keeps source and sk",
+			"https://example.com/download?source=github&sk=1&utm_source=medium",
+			"https://example.com/download?source=github&sk=1",
+		},
+		{
+			"utm params everywhere",
+			"https://example.com/?a=1&utm_source=medium&utm_campaign=x&b=2",
+			"https://example.com/?a=1&b=2",
+		},
+		{
+			"redirect wrapper",
+			"https://medium.com/r/?url=https%3A%2F%2Fexample.com%2Fpage%3Fsource%3Dpost_page%26id%3D1",
+			"https://example.com/page?id=1",
+		},
+		{
+			"nested redirect wrapper",
+			"https://medium.com/r/?url=https%3A%2F%2Fmedium.com%2Fr%2F%3Furl%3Dhttps%253A%252F%252Fexample.com",
+			"https://example.com",
+		},
+		{"nothing to strip", "https://example.com/?q=go", "https://example.com/?q=go"},
+		{"relative", "/post/a-post/?source=x", "/post/a-post/?source=x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeURL(tt.raw, patterns, customHosts); got != tt.want {
+				t.Errorf("normalizeURL(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeLinksCustomDomain(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<a href="https://coder.today/next-6f5e4d3c2b1a?source=rss">next</a>` +
+			`<a href="https://example.org/?source=rss">other</a>` +
+			`<footer><a href="https://coder.today/a-post-1a2b3c4d5e6f" class="p-canonical">Canonical link</a></footer>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom}
+	changes := p.NormalizeLinks(splitList(DefaultStripParams))
+
+	want := []string{"https://coder.today/next-6f5e4d3c2b1a?source=rss => https://coder.today/next-6f5e4d3c2b1a"}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got changes %q, want %q", changes, want)
+	}
+}
@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestInterruptedLists(t *testing.T) {
+	p := loadPost(t, "lists.html")
+	p.ContinueInterruptedLists()
+
+	got := newTestManager(Config{Target: TargetHugo, ParagraphAnchors: ParagraphAnchorsNone}).ConvertBody(p)
+	if want := readGolden(t, "lists.md"); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
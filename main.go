@@ -11,6 +11,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/google/uuid"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -29,6 +30,14 @@ const (
 	HImagesDirName        = "img"    // directory where the images will be downloaded to
 	MarkdownFileExtension = ".md"    // file extension of the Markdown files
 	DraftPrefix           = "draft_"
+	CodeLanguageAttr      = "data-m2h-lang"   // attribute used to mark the language of a code block
+	CodeHTMLAttr          = "data-m2h-html"   // attribute used to mark code blocks to be rendered as HTML
+	LinkCardClass         = "m2h-link-card"   // class of the placeholders of link preview cards
+	HeadingAnchorAttr     = "data-m2h-anchor" // attribute used to mark the anchor of a heading
+	EquationAttr          = "data-m2h-math"   // attribute holding the LaTeX source of an equation
+	EquationDisplayAttr   = "data-m2h-block"  // attribute used to mark equations to be rendered as a block
+	FootnoteAttr          = "data-m2h-fn"     // attribute used to mark the label of a footnote marker
+	FootnoteTextAttr      = "data-m2h-fntext" // attribute used to mark the label of a footnote paragraph
 
 	PostTemplate = `---
 title: "{{ .Title }}"
@@ -36,6 +45,10 @@ author: "{{ .Author }}"
 date: {{ .Date }}
 lastmod: {{ .Lastmod }}
 {{ if eq .Draft true }}draft: {{ .Draft }}{{end}}
+{{ if .TOC }}toc: true{{end}}
+{{ if .Math }}math: true{{end}}
+{{ if .CanonicalURL }}{{ .CanonicalURLKey }}: "{{ .CanonicalURL }}"{{end}}
+{{ if .OriginalURL }}originalUrl: "{{ .OriginalURL }}"{{end}}
 description: "{{ .Description }}"
 
 subtitle: "{{ .Subtitle }}"
@@ -46,10 +59,13 @@ subtitle: "{{ .Subtitle }}"
 {{ if .Images }}images:
 {{ range .Images }} - "{{.GetHugoSource}}"
 {{end}}{{end}}
-{{ if .Canonical }}
+{{ if .Highlights }}highlights:
+{{ range .Highlights }} - {{ printf "%q" . }}
+{{end}}{{end}}
+{{ if .Aliases }}
 aliases:
-- "/{{ .Canonical }}"
-{{end}}
+{{ range .Aliases }}- "{{.}}"
+{{end}}{{end}}
 ---
 
 {{ .Body }}
@@ -68,15 +84,54 @@ type ConverterManager struct {
 	PostsPath  string // OutputPath/post
 	ImagesPath string // OutputPath/post/images
 
-	// Ignore empty articles
-	IgnoreEmpty bool
+	// The Medium username of the author, read from the archive
+	Username string
+
+	// The paths of the converted posts on the new site, by Medium post id
+	PostIndex *PostIndex
+
+	// The user provided options for the conversion
+	Config
 	MDConverter *md.Converter
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == CheckLinksCommand {
+		runCheckLinks(os.Args[2:])
+		return
+	}
+
 	// define input flags
 	zipF := flag.String("f", "medium-export.zip", "the medium-export.zip file from Medium")
 	ignoreEmpty := flag.Bool("e", false, "ignore empty articles")
+	linkCards := flag.String("link-cards", LinkCardsNone, "link preview card rendering: none (plain link), shortcode, html")
+	separator := flag.String("section-separator", "---", "the markdown to separate Medium sections with, empty for none")
+	pullquotes := flag.String("pullquotes", PullquotesBlockquote, "pull quote rendering: blockquote, html (classed blockquote), shortcode")
+	headingLevel := flag.Int("heading-level", 0, "the level to shift the top level headings to, closing any gaps, 0 to keep as is")
+	toc := flag.String("toc", TOCNone, "table of contents: none, front-matter (toc: true), inline")
+	anchors := flag.Bool("heading-anchors", false, "add slugified anchors to headings, keeping Medium anchors as alternates")
+	paragraphAnchors := flag.String("paragraph-anchors", ParagraphAnchorsNone, "keep Medium paragraph anchors: none, html, attributes")
+	highlights := flag.String("highlights", HighlightsMark, "highlight and quote annotation rendering: mark, shortcode, text")
+	footnotes := flag.Bool("footnotes", true, "convert superscript markers with matching trailing paragraphs to footnotes")
+	highlightsFM := flag.Bool("highlights-front-matter", false, "add the highlights of the post to the front matter")
+	featured := flag.String("featured", FeaturedMarkedOrFirst, "featured image selection strategy: auto, featured, first, largest, none")
+	cover := flag.Bool("cover", false, "exclude the featured image from the post body, for themes that render it as a cover")
+	target := flag.String("target", TargetHugo, "the output target: hugo, html, jekyll, zola")
+	tweets := flag.String("tweets", TweetsEmbed, "tweet rendering: embed (as supported by the target), static")
+	guessLanguages := flag.Bool("guess-languages", true, "infer the language of preformatted code blocks from the code")
+	codeFormatting := flag.String("code-formatting", CodeFormattingReport, "inline formatting in code blocks: drop, report, html")
+	languagesF := flag.String("languages", "", "a JSON file mapping file extensions or names to code block languages")
+	footer := flag.String("footer", DefaultFooter, "the template of the footer appended to the posts, empty for no footer")
+	footerF := flag.String("footer-file", "", "a file with the template of the footer, instead of -footer")
+	originalURL := flag.Bool("original-url", false, "add the Medium url of the post to the front matter as originalUrl")
+	canonical := flag.String("canonical", CanonicalNone, "canonical url front matter: none, medium, site (needs -site-url)")
+	siteURL := flag.String("site-url", "", "the base url of the new site, https://example.com")
+	permalink := flag.String("permalink", DefaultPermalink, "the template of the path of the converted posts on the new site")
+	stripParams := flag.String("strip-params", DefaultStripParams, "comma separated patterns of the query params to remove from links, empty for none")
+	internalLinks := flag.String("internal-links", InternalLinksPath, "links between the converted posts: path, ref, relref (hugo shortcodes)")
+	aliases := flag.String("aliases", DefaultAlias, "comma separated templates of the old paths to add as aliases, empty for none")
+	redirects := flag.String("redirects", "", "comma separated redirect files to generate from the aliases: netlify, nginx, apache, cloudflare")
+	overridesF := flag.String("overrides", "", "a JSON file with per post overrides, keyed by the exported HTML file name")
 	flag.Parse()
 
 	// sanitize and validate input
@@ -86,8 +141,73 @@ func main() {
 		os.Exit(1)
 	}
 
+	conf := Config{
+		IgnoreEmpty:           *ignoreEmpty,
+		FeaturedImageStrategy: *featured,
+		ExcludeFeaturedImage:  *cover,
+		Target:                *target,
+		Tweets:                *tweets,
+		LinkCards:             *linkCards,
+		Pullquotes:            *pullquotes,
+		SectionSeparator:      *separator,
+		Highlights:            *highlights,
+		HeadingLevel:          *headingLevel,
+		TOC:                   *toc,
+		HeadingAnchors:        *anchors,
+		ParagraphAnchors:      *paragraphAnchors,
+		HighlightsFrontMatter: *highlightsFM,
+		Footnotes:             *footnotes,
+		GuessCodeLanguages:    *guessLanguages,
+		CodeFormatting:        *codeFormatting,
+		Footer:                *footer,
+		OriginalURL:           *originalURL,
+		Canonical:             *canonical,
+		SiteURL:               strings.TrimSuffix(*siteURL, "/"),
+		Permalink:             *permalink,
+		InternalLinks:         *internalLinks,
+		StripParams:           splitList(*stripParams),
+		Aliases:               splitList(*aliases),
+		Redirects:             splitList(*redirects),
+	}
+
+	if len(*footerF) > 0 {
+		content, err := ioutil.ReadFile(*footerF)
+		if err != nil {
+			printError("couldn't read footer template: %s", err)
+			os.Exit(1)
+		}
+
+		conf.Footer = strings.TrimSpace(string(content))
+	}
+
+	if len(*overridesF) > 0 {
+		overrides, err := loadOverrides(*overridesF)
+		if err != nil {
+			printError("couldn't read overrides: %s", err)
+			os.Exit(1)
+		}
+
+		conf.Overrides = overrides
+	}
+
+	if len(*languagesF) > 0 {
+		languages, err := loadLanguages(*languagesF)
+		if err != nil {
+			printError("couldn't read languages: %s", err)
+			os.Exit(1)
+		}
+
+		conf.Languages = languages
+	}
+
+	err := conf.validate()
+	if err != nil {
+		printError("invalid options: %s", err)
+		os.Exit(1)
+	}
+
 	// extract archive and prep for reading
-	mgr, err := newConverterManager(zipFilePath, *ignoreEmpty)
+	mgr, err := newConverterManager(zipFilePath, conf)
 	if err != nil {
 		printError("error while setting up converter: %s", err)
 		cleanup(mgr)
@@ -109,8 +229,13 @@ func main() {
 		printError("couldn't read username from archive, self links will not be fixed: %s", err)
 	} else {
 		fmt.Printf("Medium username: \t%s\n", bold(username))
+		mgr.Username = username
 	}
 
+	// index the posts before the conversion, so that links to posts converted later can be fixed
+	mgr.IndexPosts(files)
+	fmt.Printf("Posts indexed: \t\t%s\n", boldf("%d", mgr.PostIndex.Len()))
+
 	fmt.Print("Ignore empty articles: \t", )
 	if mgr.IgnoreEmpty {
 		printCheckMark()
@@ -118,11 +243,21 @@ func main() {
 		printXMark()
 	}
 
+	fmt.Printf("\nOutput target: \t\t%s", bold(mgr.Target))
+	fmt.Printf("\nFeatured image: \t%s", bold(mgr.FeaturedImageStrategy))
+	if mgr.ExcludeFeaturedImage {
+		fmt.Print(", excluded from body")
+	}
+
 	fmt.Println()
 
 	// count failures
 	ignoreList := make([]string, 0)
 	errorList := make([]string, 0)
+	uncertainList := make([]string, 0)
+	formattingList := make([]string, 0)
+	redirectList := make([]*Redirect, 0)
+	normalizedList := make([]string, 0)
 	successCount := 0
 
 	// iterate each html file and generate md
@@ -149,6 +284,19 @@ func main() {
 
 		printDot()
 
+		// remove tracking params and redirect wrappers before the links are processed
+		if len(mgr.StripParams) > 0 {
+			for _, change := range post.NormalizeLinks(mgr.StripParams) {
+				normalizedList = append(normalizedList, fmt.Sprintf("%s: %s", f.Name(), change))
+			}
+		}
+		printDot()
+
+		// keep the details of link preview cards before they are cleaned up
+		if mgr.LinkCards != LinkCardsNone {
+			post.ConvertLinkCards()
+		}
+
 		// cleanup unwanted elements
 		post.PruneMediumSpecifics()
 
@@ -181,7 +329,7 @@ func main() {
 		post.SetCanonicalName()
 		printDot()
 
-		err = post.FixSelfLinks(username)
+		err = post.FixSelfLinks(username, mgr.PostIndex, mgr.InternalLinks)
 		if err != nil {
 			printRedDot()
 		} else {
@@ -196,29 +344,73 @@ func main() {
 		}
 
 		// determine markdown filename
+		post.SetMdFilename()
+		printDot()
 
-		// 1. filename prefix, usually <date>_
-		//datetime ISO 2018-09-25T14:13:46.823Z
-		//we only keep the date for simplicity
-		createdDate := strings.Split(post.Date, "T")[0]
-		prefix := createdDate
+		// canonical url and aliases for the front matter, the site url depends on the filename
+		err = mgr.SetCanonicalURL(post)
+		if err != nil {
+			printRedDot()
+		} else {
+			printDot()
+		}
 
-		// drafts get "draft_" as the prefix
-		if post.Draft {
-			prefix = DraftPrefix
+		err = mgr.SetAliases(post)
+		if err != nil {
+			printRedDot()
+		} else {
+			printDot()
 		}
+
+		// download images
+		mgr.ProcessImages(post)
 		printDot()
 
-		// 2. clean up the title
-		slug := generateSlug(post.Title)
+		// keep the numbering of lists interrupted by images or code
+		post.ContinueInterruptedLists()
 		printDot()
 
-		// 3. collect them to the filename - done
-		post.MdFilename = prefix + "_" + slug + MarkdownFileExtension
+		// the notes heading of the footnotes is removed, before the headings are processed
+		if mgr.Footnotes {
+			post.ConvertFootnotes()
+		}
 		printDot()
 
-		// download images
-		mgr.ProcessImages(post)
+		// medium uses h3 and h4 for headings, shift them below the title
+		if mgr.HeadingLevel > 0 {
+			post.NormalizeHeadings(mgr.HeadingLevel)
+		}
+		printDot()
+
+		// the inline table of contents links to the heading anchors
+		if mgr.HeadingAnchors || mgr.TOC == TOCInline {
+			post.AnnotateHeadingAnchors()
+		}
+		post.TOC = mgr.TOC == TOCFrontMatter
+		post.RewriteAnchorLinks()
+		printDot()
+
+		// collect the highlights for the front matter
+		if mgr.HighlightsFrontMatter {
+			post.PopulateHighlights()
+		}
+		printDot()
+
+		// determine code block languages
+		post.AnnotateCodeLanguages(mgr.GetOverride(post.HTMLFileName), mgr.GuessCodeLanguages)
+		for _, g := range post.UncertainLanguages {
+			uncertainList = append(uncertainList, fmt.Sprintf(
+				"%s: code block %d => %s (%.0f%%)", f.Name(), g.Block, g.Language, g.Confidence*100))
+		}
+		printDot()
+
+		// find code blocks with inline formatting
+		post.AnnotateCodeFormatting(mgr.CodeFormatting == CodeFormattingHTML)
+		if mgr.CodeFormatting == CodeFormattingReport {
+			for _, block := range post.FormattedCodeBlocks {
+				formattingList = append(formattingList, fmt.Sprintf("%s: code block %d", f.Name(), block))
+			}
+		}
 		printDot()
 
 		// Change text for canonical link display on the bottom of the post
@@ -228,18 +420,25 @@ func main() {
 
 		// all done, generate the markdown
 		// 1. body
-		body := mgr.MDConverter.Convert(post.DOM.Find("div.section-inner"))
-		post.Body = strings.TrimSpace(body)
+		post.Body = mgr.ConvertBody(post)
+		if mgr.TOC == TOCInline {
+			if toc := post.TableOfContents(); len(toc) > 0 {
+				post.Body = toc + "\n\n" + post.Body
+			}
+		}
 		printDot()
 
 		// 2. footer
-		ftdom := post.DOM.Find("footer")
-		when := ftdom.Find("time.dt-published").Text()
-		who := ftdom.Find("a.p-author").Text()
-		mLink := ftdom.Find("a.p-canonical").AttrOr("href", "")
+		footer, err := mgr.RenderFooter(post)
+		if err != nil {
+			printXError("rendering footer => %s", err)
+			errorList = append(errorList, f.Name())
+			continue
+		}
 
-		footer := fmt.Sprintf("\n\n* * *\nWritten on %s by %s.\n\nOriginally published on [Medium](%s)", when, who, mLink)
-		post.Body += footer
+		if len(footer) > 0 {
+			post.Body += "\n\n" + footer
+		}
 		printDot()
 
 		written, err := mgr.Write(post)
@@ -257,6 +456,12 @@ func main() {
 		} else {
 			printDot()
 			successCount++
+
+			// drafts were never published, there are no old links to redirect
+			if !post.Draft {
+				redirectList = append(redirectList, mgr.PostRedirects(post)...)
+			}
+
 			fmt.Print(" ")
 			printCheckMark()
 		}
@@ -279,6 +484,37 @@ func main() {
 		}
 	}
 
+	if len(uncertainList) > 0 {
+		color.Yellow("The following code block languages were guessed with low confidence and not set, use the overrides file to set them if needed:")
+		for i, uncertain := range uncertainList {
+			fmt.Printf("%02d: %s\n", i+1, uncertain)
+		}
+	}
+
+	if len(formattingList) > 0 {
+		color.Yellow("The following code blocks lost their inline formatting, use -code-formatting html to keep it:")
+		for i, formatted := range formattingList {
+			fmt.Printf("%02d: %s\n", i+1, formatted)
+		}
+	}
+
+	if len(normalizedList) > 0 {
+		err = ioutil.WriteFile(
+			filepath.Join(mgr.OutputPath, NormalizedLinksFileName), []byte(strings.Join(normalizedList, "\n")+"\n"), 0644)
+		if err != nil {
+			printError("couldn't write the normalized links: %s", err)
+		} else {
+			color.Yellow("%d links were normalized, see %s", len(normalizedList), NormalizedLinksFileName)
+		}
+	}
+
+	if len(mgr.Redirects) > 0 {
+		err = writeRedirects(mgr.OutputPath, mgr.Redirects, redirectList, &mgr.Config)
+		if err != nil {
+			printError("couldn't write redirect files: %s", err)
+		}
+	}
+
 	fmt.Println()
 	fmt.Println()
 	fmt.Printf("%s posts successfully converted to Hugo compatible Markdown\n", bold(successCount))
@@ -294,7 +530,7 @@ func main() {
 //
 // Returns a pointer to the ConverterManager struct, if any failures occur
 // during the process, the error will be returned
-func newConverterManager(archive string, ignoreEmpty bool) (*ConverterManager, error) {
+func newConverterManager(archive string, conf Config) (*ConverterManager, error) {
 	// build dir path values
 	pwd, err := os.Getwd()
 	if err != nil {
@@ -335,28 +571,36 @@ func newConverterManager(archive string, ignoreEmpty bool) (*ConverterManager, e
 		return nil, fmt.Errorf("couldn't find posts content in the medium extract archive: %s", oIn)
 	}
 
-	// create a markdown converter
-	op := md.Options{
-		CodeBlockStyle: "fenced",
-	}
-	converter := md.NewConverter("", true, &op)
-	// don't remove br tags
-	converter.Keep("br")
-	converter.AddRules(ruleOverrides...)
-
 	mgr := &ConverterManager{
 		InPath:          oIn,
 		MediumPostsPath: mediumPosts,
 		OutputPath:      oOut,
 		PostsPath:       postsPath,
 		ImagesPath:      imagesPath,
-		IgnoreEmpty:     ignoreEmpty,
-		MDConverter:     converter,
+		Config:          conf,
 	}
+	mgr.MDConverter = newMarkdownConverter(&mgr.Config)
 
 	return mgr, nil
 }
 
+// newMarkdownConverter creates a markdown converter with the rule overrides
+// and the rules for the given options
+func newMarkdownConverter(conf *Config) *md.Converter {
+	op := md.Options{
+		CodeBlockStyle: "fenced",
+	}
+	converter := md.NewConverter("", true, &op)
+	// don't remove br tags
+	converter.Keep("br")
+	converter.AddRules(ruleOverrides...)
+
+	// rules that depend on the user provided options take precedence
+	converter.AddRules(configuredRules(conf)...)
+
+	return converter
+}
+
 // newPost reads a given file and parses the details in to a Post struct.
 // The HTML content of the file is also loaded as a queryable reference
 // Basic details are extracted from the resulting DOM
@@ -390,6 +634,41 @@ func newPost(fullPath string) (*Post, error) {
 	return p, nil
 }
 
+// IndexPosts collects the paths on the new site of the given Medium posts, by
+// the Medium post id, so that the links between the posts can be fixed.
+// Untitled posts are given a random name during the conversion, and aren't
+// indexed.
+func (mgr *ConverterManager) IndexPosts(files []os.FileInfo) {
+	mgr.PostIndex = newPostIndex()
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".html") || f.IsDir() {
+			continue
+		}
+
+		post, err := newPost(filepath.Join(mgr.MediumPostsPath, f.Name()))
+		if err != nil {
+			continue
+		}
+
+		post.Date, _ = post.DOM.Find("time").Attr("datetime")
+		post.Title = strings.TrimSpace(post.DOM.Find("title").Text())
+		post.SetCanonicalName()
+
+		id := mediumPostID(post.FullURL)
+		if len(id) == 0 || len(post.Title) == 0 {
+			continue
+		}
+
+		post.SetMdFilename()
+		path, err := mgr.SitePath(post)
+		if err != nil {
+			continue
+		}
+
+		mgr.PostIndex.Add(id, &IndexedPost{Path: path, MdFilename: post.MdFilename})
+	}
+}
+
 // GetMediumUserName reads the profile.html file in the extracted medium
 // export and extracts the medium username. If an error occur while
 // reading the file or the specific element containing the username cannot
@@ -488,10 +767,267 @@ func (mgr *ConverterManager) Write(p *Post) (bool, error) {
 	return true, nil
 }
 
+// PermalinkData is the data available to the permalink and alias templates,
+// the fields of the Post and the parts of the post file name, date and Medium
+// url
+type PermalinkData struct {
+	*Post
+
+	// the markdown file name without the extension, 2018-09-25_a-b-tests
+	Name string
+
+	// the slug generated from the title, a-b-tests
+	Slug string
+
+	// the parts of the post date, 2018, 09, 25
+	Year, Month, Day string
+
+	// the id of the post on Medium, f57f5c1a492
+	ID string
+
+	// the Medium username of the author
+	Username string
+
+	// the path of the post on Medium, /@user/a-b-tests-f57f5c1a492
+	MediumPath string
+}
+
+// newPermalinkData collects the details of the given Post available to the
+// permalink and alias templates
+func (mgr *ConverterManager) newPermalinkData(p *Post) (*PermalinkData, error) {
+	name, err := p.GetFileNamePrefix()
+	if err != nil {
+		return nil, err
+	}
+
+	data := &PermalinkData{
+		Post:     p,
+		Name:     name,
+		Slug:     generateSlug(p.Title),
+		ID:       mediumPostID(p.FullURL),
+		Username: mgr.Username,
+	}
+
+	// 2018-09-25T14:13:46.823Z
+	if date := strings.Split(strings.Split(p.Date, "T")[0], "-"); len(date) == 3 {
+		data.Year, data.Month, data.Day = date[0], date[1], date[2]
+	}
+
+	if u, err := url.Parse(p.FullURL); err == nil {
+		data.MediumPath = u.Path
+	}
+
+	return data, nil
+}
+
+// SitePath renders the configured permalink template for the given Post, the
+// path of the post on the new site
+func (mgr *ConverterManager) SitePath(p *Post) (string, error) {
+	tmpl, err := template.New("permalink").Parse(mgr.Permalink)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := mgr.newPermalinkData(p)
+	if err != nil {
+		return "", err
+	}
+
+	var path strings.Builder
+	err = tmpl.Execute(&path, data)
+	if err != nil {
+		return "", err
+	}
+
+	return "/" + strings.TrimPrefix(strings.TrimSpace(path.String()), "/"), nil
+}
+
+// SetAliases renders the configured alias templates for the given Post. Empty
+// and duplicate aliases are skipped.
+func (mgr *ConverterManager) SetAliases(p *Post) error {
+	p.Aliases = make([]string, 0)
+	if len(mgr.Aliases) == 0 {
+		return nil
+	}
+
+	data, err := mgr.newPermalinkData(p)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, a := range mgr.Aliases {
+		tmpl, err := template.New("alias").Parse(a)
+		if err != nil {
+			return err
+		}
+
+		var alias strings.Builder
+		err = tmpl.Execute(&alias, data)
+		if err != nil {
+			return err
+		}
+
+		// empty path segments mean a value was missing, /{{ .Canonical }} or /@{{ .Username }}/{{ .Canonical }}
+		path := "/" + strings.TrimPrefix(strings.TrimSpace(alias.String()), "/")
+		if path == "/" || hasEmptySegment(path, strings.HasSuffix(strings.TrimSpace(a), "/")) || seen[path] {
+			continue
+		}
+
+		seen[path] = true
+		p.Aliases = append(p.Aliases, path)
+	}
+
+	return nil
+}
+
+// hasEmptySegment reports whether the given path has an empty segment, or a
+// segment with only the @ of a username. A trailing slash doesn't count as an
+// empty segment if the path is expected to end with one.
+func hasEmptySegment(path string, trailingSlash bool) bool {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	if trailingSlash {
+		segments = segments[:len(segments)-1]
+	}
+
+	for _, s := range segments {
+		if len(s) == 0 || s == "@" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PostRedirects returns the redirects from the aliases of the given Post to
+// the path of the post on the new site
+func (mgr *ConverterManager) PostRedirects(p *Post) []*Redirect {
+	to, err := mgr.SitePath(p)
+	if err != nil {
+		return nil
+	}
+
+	redirects := make([]*Redirect, 0)
+	for _, a := range p.Aliases {
+		if a != to {
+			redirects = append(redirects, &Redirect{From: a, To: to})
+		}
+	}
+
+	return redirects
+}
+
+// SetCanonicalURL sets the canonical url of the given Post, along with the
+// front matter key of the output target. The canonical url points to the post
+// on Medium or on the new site, based on the configured mode. The Medium url
+// is set as the original url if asked for, or if there's a canonical url.
+func (mgr *ConverterManager) SetCanonicalURL(p *Post) error {
+	p.CanonicalURLKey = canonicalURLKeys[mgr.Target]
+
+	switch mgr.Canonical {
+	case CanonicalMedium:
+		p.CanonicalURL = p.FullURL
+	case CanonicalSite:
+		path, err := mgr.SitePath(p)
+		if err != nil {
+			return err
+		}
+
+		p.CanonicalURL = mgr.SiteURL + path
+	}
+
+	// the medium url is kept along with a canonical url
+	if mgr.OriginalURL || len(p.CanonicalURL) > 0 {
+		p.OriginalURL = p.FullURL
+	}
+
+	return nil
+}
+
+// FooterData is the data available to the footer template, the fields of the
+// Post and the details of the Medium footer of the post
+type FooterData struct {
+	*Post
+
+	// the publishing time as shown by Medium, September 25, 2018
+	Published string
+
+	// the url of the post on Medium
+	MediumURL string
+}
+
+// RenderFooter renders the configured footer template for the given Post, an
+// empty string if the footer is disabled
+func (mgr *ConverterManager) RenderFooter(p *Post) (string, error) {
+	if len(strings.TrimSpace(mgr.Footer)) == 0 {
+		return "", nil
+	}
+
+	tmpl, err := template.New("footer").Parse(mgr.Footer)
+	if err != nil {
+		return "", err
+	}
+
+	ftdom := p.DOM.Find("footer")
+	data := &FooterData{
+		Post:      p,
+		Published: ftdom.Find("time.dt-published").Text(),
+		MediumURL: ftdom.Find("a.p-canonical").AttrOr("href", p.FullURL),
+	}
+
+	var footer strings.Builder
+	err = tmpl.Execute(&footer, data)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(footer.String()), nil
+}
+
+// ConvertBody converts the Medium sections of the given Post to markdown in
+// order, joining them with the configured section separator where Medium
+// shows a section divider
+func (mgr *ConverterManager) ConvertBody(p *Post) string {
+	sections := p.DOM.Find("section.section")
+	if sections.Length() == 0 {
+		return strings.TrimSpace(mgr.MDConverter.Convert(p.DOM.Find("div.section-inner")))
+	}
+
+	body := ""
+	sections.Each(func(i int, section *goquery.Selection) {
+		// the section content holds all the layouts of the section, including full width images, while the
+		// divider is kept out
+		content := section.Find("div.section-content")
+		if content.Length() == 0 {
+			content = section.Find("div.section-inner")
+		}
+
+		converted := strings.TrimSpace(mgr.MDConverter.Convert(content))
+		if len(converted) == 0 {
+			return
+		}
+
+		if len(body) > 0 {
+			if len(mgr.SectionSeparator) > 0 && section.Find("hr.section-divider").Length() > 0 {
+				body += fmt.Sprintf("\n\n%s", mgr.SectionSeparator)
+			}
+
+			body += "\n\n"
+		}
+
+		body += converted
+	})
+
+	return body
+}
+
 // ProcessImages reads a give Post for img elements, downloads them to a
 // directory, and changes the src values to point to the downloaded
-// location
+// location. Images of equations are converted to LaTeX instead.
 func (mgr *ConverterManager) ProcessImages(p *Post) {
+	// equation images are converted to LaTeX, and don't need to be downloaded
+	p.ConvertEquationImages()
+
 	images := p.DOM.Find("img")
 	printDot()
 
@@ -518,22 +1054,89 @@ func (mgr *ConverterManager) ProcessImages(p *Post) {
 		printDot()
 
 		// the suffix after # is useful for styling the image in a way similar to what medium does
-		imageSrcAttr := fmt.Sprintf("%s#%s", img.GetHugoSource(), extractMediumImageStyle(imgDomElement))
-		imgDomElement.SetAttr("src", imageSrcAttr)
-		printDot()
-
-		// if the image is the featured image, mark it down
-		if _, isFeatured := imgDomElement.Attr("data-is-featured"); isFeatured {
-			p.FeaturedImage = img.GetHugoSource()
+		imageSrcAttr := img.GetHugoSource()
+		if !img.Thumbnail {
+			imageSrcAttr = fmt.Sprintf("%s#%s", imageSrcAttr, extractMediumImageStyle(imgDomElement))
 		}
+		imgDomElement.SetAttr("src", imageSrcAttr)
 		printDot()
 	})
 
-	// if no images were marked as featured, get the first image
-	if len(p.FeaturedImage) == 0 && len(p.Images) > 0 {
-		p.FeaturedImage = p.Images[0].GetHugoSource()
+	// pick the featured image
+	featured := mgr.SelectFeaturedImage(p)
+	if featured != nil {
+		p.FeaturedImage = featured.GetHugoSource()
+
+		// the theme will render the featured image as a cover, avoid showing it twice
+		if mgr.ExcludeFeaturedImage {
+			featured.RemoveFromBody()
+		}
 	}
 	printDot()
 
 	return
 }
+
+// SelectFeaturedImage picks the featured image of the given Post from the
+// processed images based on the configured strategy, honouring any per post
+// override. Returns nil if the post should not have a featured image.
+func (mgr *ConverterManager) SelectFeaturedImage(p *Post) *Image {
+	if len(p.Images) == 0 {
+		return nil
+	}
+
+	// a per post override takes precedence over the strategy
+	override := mgr.GetOverride(p.HTMLFileName)
+	if override.FeaturedImage != nil {
+		for _, img := range p.Images {
+			if img.Index == *override.FeaturedImage {
+				return img
+			}
+		}
+
+		return nil
+	}
+
+	// link card thumbnails are not part of the post content
+	candidates := make([]*Image, 0)
+	var marked *Image
+	for _, img := range p.Images {
+		if img.Thumbnail {
+			continue
+		}
+
+		candidates = append(candidates, img)
+		if img.Featured && marked == nil {
+			marked = img
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch mgr.FeaturedImageStrategy {
+	case FeaturedNone:
+		return nil
+	case FeaturedMarked:
+		return marked
+	case FeaturedFirst:
+		return candidates[0]
+	case FeaturedLargest:
+		largest := candidates[0]
+		for _, img := range candidates[1:] {
+			if img.Area() > largest.Area() {
+				largest = img
+			}
+		}
+
+		return largest
+	default:
+		// if no images were marked as featured, get the first image
+		if marked != nil {
+			return marked
+		}
+
+		return candidates[0]
+	}
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// convertHTML converts the given HTML fragment with the rules for the given
+// options
+func convertHTML(t *testing.T, conf Config, fragment string) string {
+	t.Helper()
+
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		t.Fatalf("couldn't parse html: %s", err)
+	}
+
+	return strings.TrimSpace(newMarkdownConverter(&conf).Convert(dom.Find("body")))
+}
+
+// loadPost reads the given Medium post from the testdata directory
+func loadPost(t *testing.T, name string) *Post {
+	t.Helper()
+
+	p, err := newPost(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("couldn't read post %s: %s", name, err)
+	}
+
+	return p
+}
+
+// readGolden reads the expected output from the given file in the testdata
+// directory, without the trailing newline
+func readGolden(t *testing.T, name string) string {
+	t.Helper()
+
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("couldn't read golden file %s: %s", name, err)
+	}
+
+	return strings.TrimSuffix(string(content), "\n")
+}
+
+// newTestManager creates a ConverterManager for the given options, without
+// the input and output directories
+func newTestManager(conf Config) *ConverterManager {
+	mgr := &ConverterManager{Config: conf}
+	mgr.MDConverter = newMarkdownConverter(&mgr.Config)
+
+	return mgr
+}
+
+func TestConvertBodySections(t *testing.T) {
+	tests := []struct {
+		name      string
+		separator string
+		want      string
+	}{
+		{
+			name:      "separator",
+			separator: "* * *",
+			want:      "sections.md",
+		},
+		{
+			name: "no separator",
+			want: "sections-no-separator.md",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newTestManager(Config{
+				Target:           TargetHugo,
+				SectionSeparator: tt.separator,
+			})
+
+			got := mgr.ConvertBody(loadPost(t, "sections.html"))
+			if want := readGolden(t, tt.want); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestSelectFeaturedImage(t *testing.T) {
+	index := func(i int) *int { return &i }
+	images := func(marked bool) []*Image {
+		return []*Image{
+			{Index: 0, Width: 400, Height: 300},
+			{Index: 1, Width: 800, Height: 600, Featured: marked},
+			{Index: 2, Width: 2000, Height: 600},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		strategy string
+		marked   bool
+		override *int
+		want     int // index of the selected image, -1 for none
+	}{
+		{"auto", FeaturedMarkedOrFirst, true, nil, 1},
+		{"auto without a marked image", FeaturedMarkedOrFirst, false, nil, 0},
+		{"featured", FeaturedMarked, true, nil, 1},
+		{"featured without a marked image", FeaturedMarked, false, nil, -1},
+		{"first", FeaturedFirst, true, nil, 0},
+		{"largest", FeaturedLargest, true, nil, 2},
+		{"none", FeaturedNone, true, nil, -1},
+		{"override", FeaturedNone, true, index(2), 2},
+		{"override first", FeaturedLargest, true, index(0), 0},
+		{"negative override", FeaturedMarkedOrFirst, true, index(-1), -1},
+		{"override out of range", FeaturedMarkedOrFirst, true, index(5), -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := &ConverterManager{Config: Config{
+				FeaturedImageStrategy: tt.strategy,
+				Overrides:             map[string]*PostOverride{"post.html": {FeaturedImage: tt.override}},
+			}}
+
+			got := -1
+			if img := mgr.SelectFeaturedImage(&Post{HTMLFileName: "post.html", Images: images(tt.marked)}); img != nil {
+				got = img.Index
+			}
+
+			if got != tt.want {
+				t.Errorf("got image %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	mgr := &ConverterManager{Config: Config{FeaturedImageStrategy: FeaturedMarkedOrFirst}}
+	if img := mgr.SelectFeaturedImage(&Post{HTMLFileName: "post.html"}); img != nil {
+		t.Errorf("got image %d for a post without images", img.Index)
+	}
+
+	// link card thumbnails are not considered
+	thumbnails := []*Image{{Index: 0, Width: 4000, Height: 4000, Thumbnail: true}, {Index: 1, Width: 400, Height: 300}}
+	for _, strategy := range []string{FeaturedMarkedOrFirst, FeaturedFirst, FeaturedLargest} {
+		mgr := &ConverterManager{Config: Config{FeaturedImageStrategy: strategy}}
+		if img := mgr.SelectFeaturedImage(&Post{HTMLFileName: "post.html", Images: thumbnails}); img == nil || img.Index != 1 {
+			t.Errorf("%s: got %v, want image 1 instead of the link card thumbnail", strategy, img)
+		}
+	}
+}
+
+func TestRenderFooter(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<footer>` +
+		`<p>By <a class="p-author h-card" href="https://medium.com/@author">Author</a> on ` +
+		`<a href="https://medium.com/p/f57f5c1a492"><time class="dt-published" datetime="2018-09-25T10:00:00.000Z">September 25, 2018</time></a>.</p>` +
+		`<p><a href="https://medium.com/@author/a-post-f57f5c1a492" class="p-canonical">Canonical link</a></p>` +
+		`</footer>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom, Title: "A Post", Author: "Author", Tags: []string{"go", "testing"}}
+
+	tests := []struct {
+		name   string
+		footer string
+		want   string
+	}{
+		{
+			name:   "default",
+			footer: DefaultFooter,
+			want: "* * *\nWritten on September 25, 2018 by Author.\n\n" +
+				"Originally published on [Medium](https://medium.com/@author/a-post-f57f5c1a492)",
+		},
+		{
+			name:   "custom",
+			footer: `_{{ .Title }}_ was first published on {{ .Published }}{{ range .Tags }} #{{ . }}{{ end }}`,
+			want:   "_A Post_ was first published on September 25, 2018 #go #testing",
+		},
+		{
+			name:   "empty",
+			footer: " \n",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestManager(Config{Footer: tt.footer}).RenderFooter(p)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := newTestManager(Config{Footer: "{{ .Missing }}"}).RenderFooter(p); err == nil {
+		t.Errorf("expected an error for a template using an unknown field")
+	}
+}
+
+func TestSetCanonicalURL(t *testing.T) {
+	const mediumURL = "https://medium.com/@author/a-b-tests-f57f5c1a492"
+
+	tests := []struct {
+		name         string
+		conf         Config
+		wantKey      string
+		wantURL      string
+		wantOriginal string
+	}{
+		{
+			name:    "none",
+			conf:    Config{Target: TargetHugo, Canonical: CanonicalNone},
+			wantKey: "canonicalURL",
+		},
+		{
+			name:         "original url only",
+			conf:         Config{Target: TargetHugo, Canonical: CanonicalNone, OriginalURL: true},
+			wantKey:      "canonicalURL",
+			wantOriginal: mediumURL,
+		},
+		{
+			name:         "hugo medium",
+			conf:         Config{Target: TargetHugo, Canonical: CanonicalMedium},
+			wantKey:      "canonicalURL",
+			wantURL:      mediumURL,
+			wantOriginal: mediumURL,
+		},
+		{
+			name:         "jekyll medium",
+			conf:         Config{Target: TargetJekyll, Canonical: CanonicalMedium},
+			wantKey:      "canonical_url",
+			wantURL:      mediumURL,
+			wantOriginal: mediumURL,
+		},
+		{
+			name:         "zola site",
+			conf:         Config{Target: TargetZola, Canonical: CanonicalSite, SiteURL: "https://example.com", Permalink: DefaultPermalink},
+			wantKey:      "canonical_url",
+			wantURL:      "https://example.com/" + HContentType + "/2018-09-25_a-b-tests/",
+			wantOriginal: mediumURL,
+		},
+		{
+			name: "site with a permalink template",
+			conf: Config{
+				Target:    TargetHugo,
+				Canonical: CanonicalSite,
+				SiteURL:   "https://example.com",
+				Permalink: "{{ .Year }}/{{ .Month }}/{{ .Slug }}.html",
+			},
+			wantKey:      "canonicalURL",
+			wantURL:      "https://example.com/2018/09/ab-tests.html",
+			wantOriginal: mediumURL,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &Post{
+				Title:      "A/B Tests",
+				Date:       "2018-09-25T14:13:46.823Z",
+				FullURL:    mediumURL,
+				MdFilename: "2018-09-25_a-b-tests.md",
+			}
+
+			err := newTestManager(tt.conf).SetCanonicalURL(p)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if p.CanonicalURLKey != tt.wantKey || p.CanonicalURL != tt.wantURL || p.OriginalURL != tt.wantOriginal {
+				t.Errorf("got %s: %q, originalUrl: %q, want %s: %q, originalUrl: %q",
+					p.CanonicalURLKey, p.CanonicalURL, p.OriginalURL, tt.wantKey, tt.wantURL, tt.wantOriginal)
+			}
+		})
+	}
+}
+
+func TestCanonicalURLFrontMatter(t *testing.T) {
+	mgr := newTestManager(Config{Target: TargetJekyll, Canonical: CanonicalMedium})
+	mgr.PostsPath = t.TempDir()
+
+	p := &Post{Title: "A Post", Body: "text", FullURL: "https://medium.com/p/f57f5c1a492", MdFilename: "a-post.md"}
+	if err := mgr.SetCanonicalURL(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mgr.Write(p); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(mgr.PostsPath, p.MdFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"\ncanonical_url: \"https://medium.com/p/f57f5c1a492\"\n",
+		"\noriginalUrl: \"https://medium.com/p/f57f5c1a492\"\n",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("front matter doesn't contain %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestSetAliases(t *testing.T) {
+	tests := []struct {
+		name      string
+		username  string
+		canonical string
+		want      []string
+	}{
+		{
+			name:      "all values",
+			username:  "author",
+			canonical: "a-b-tests-f57f5c1a492",
+			want: []string{
+				"/a-b-tests-f57f5c1a492",
+				"/@author/a-b-tests-f57f5c1a492",
+				"/p/f57f5c1a492",
+				"/a-b-tests-f57f5c1a492/",
+			},
+		},
+		{
+			name:      "no username",
+			canonical: "a-b-tests-f57f5c1a492",
+			want:      []string{"/a-b-tests-f57f5c1a492", "/p/f57f5c1a492", "/a-b-tests-f57f5c1a492/"},
+		},
+		{
+			name:     "no canonical name",
+			username: "author",
+			want:     []string{"/p/f57f5c1a492"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mgr := newTestManager(Config{Aliases: []string{
+				DefaultAlias,
+				"/@{{ .Username }}/{{ .Canonical }}",
+				"/p/{{ .ID }}",
+				"/{{ .Canonical }}/",
+				"/{{ .Canonical }}",
+			}})
+			mgr.Username = tt.username
+
+			p := &Post{
+				Canonical:  tt.canonical,
+				FullURL:    "https://medium.com/@author/a-b-tests-f57f5c1a492",
+				MdFilename: "2018-09-25_a-b-tests.md",
+			}
+
+			if err := mgr.SetAliases(p); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if !reflect.DeepEqual(p.Aliases, tt.want) {
+				t.Errorf("got aliases %v, want %v", p.Aliases, tt.want)
+			}
+		})
+	}
+}
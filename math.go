@@ -0,0 +1,202 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// An EquationRenderer is an external service that renders LaTeX equations
+// as images, with the LaTeX source encoded in the image url
+type EquationRenderer struct {
+	Name string
+	// Hosts are the host names the renderer serves the images from
+	Hosts []string
+	// LaTeX recovers the LaTeX source from the given image url, an empty
+	// string if the url doesn't contain an equation
+	LaTeX func(u *url.URL) string
+}
+
+// equationRenderers are looked up by the host of each image in a post. An
+// entry lists every host the service serves its images from, and recovers the
+// LaTeX source from the image url, returning an empty string for images of the
+// service that aren't equations so that they are kept as images.
+var equationRenderers = []*EquationRenderer{
+	{
+		// https://latex.codecogs.com/gif.latex?\dpi{120}&space;E=mc^2
+		// https://www.codecogs.com/eqnedit.php?latex=E=mc^2
+		Name:  "codecogs",
+		Hosts: []string{"latex.codecogs.com", "codecogs.com"},
+		LaTeX: func(u *url.URL) string {
+			if strings.HasSuffix(u.Path, "eqnedit.php") {
+				return codecogsLaTeX(u.Query().Get("latex"))
+			}
+
+			latex, err := url.PathUnescape(u.RawQuery)
+			if err != nil {
+				return ""
+			}
+
+			return codecogsLaTeX(latex)
+		},
+	},
+	{
+		// https://chart.googleapis.com/chart?cht=tx&chl=E%3Dmc%5E2
+		Name:  "google-charts",
+		Hosts: []string{"chart.googleapis.com"},
+		LaTeX: func(u *url.URL) string {
+			if u.Query().Get("cht") != "tx" {
+				return ""
+			}
+
+			return u.Query().Get("chl")
+		},
+	},
+	{
+		// https://render.githubusercontent.com/render/math?math=E%3Dmc%5E2
+		Name:  "github",
+		Hosts: []string{"render.githubusercontent.com"},
+		LaTeX: func(u *url.URL) string {
+			return u.Query().Get("math")
+		},
+	},
+	{
+		// https://math.now.sh?from=E%3Dmc%5E2
+		Name:  "math.now.sh",
+		Hosts: []string{"math.now.sh"},
+		LaTeX: func(u *url.URL) string {
+			return u.Query().Get("from")
+		},
+	},
+	{
+		// https://i.upmath.me/svg/E%3Dmc%5E2
+		Name:  "upmath",
+		Hosts: []string{"i.upmath.me"},
+		LaTeX: func(u *url.URL) string {
+			parts := strings.SplitN(strings.TrimPrefix(u.EscapedPath(), "/"), "/", 2)
+			if len(parts) < 2 {
+				return ""
+			}
+
+			latex, err := url.PathUnescape(parts[1])
+			if err != nil {
+				return ""
+			}
+
+			return latex
+		},
+	},
+}
+
+var (
+	// codecogs marks inline equations with \inline
+	inlineEquationPattern = regexp.MustCompile(`^\\inline\b\s*`)
+
+	// the rendering options codecogs allows before the equation, such as
+	// \dpi{120} \bg_white \fn_cm \large
+	codecogsOptionPattern = regexp.MustCompile(`^\s*\\(dpi\{\d+\}|bg\{\w+\}|bg_[a-z]+|fn_[a-z]+|[a-zA-Z]+)\s*`)
+	codecogsSizes         = map[string]bool{
+		"tiny": true, "small": true, "normalsize": true, "large": true, "Large": true, "LARGE": true, "huge": true,
+		"Huge": true, "inline": true,
+	}
+
+	// the characters in LaTeX that markdown would otherwise treat as escapes
+	// or emphasis
+	equationEscaper = strings.NewReplacer(`\`, `\\`, "_", `\_`, "*", `\*`)
+)
+
+// An Equation is a LaTeX equation recovered from an equation image
+type Equation struct {
+	LaTeX string
+	// whether the equation should be rendered as a block rather than inline
+	Display bool
+}
+
+// equationFromURL recovers the LaTeX equation from the given image url if it
+// was rendered by one of the known equation renderers, nil otherwise
+func equationFromURL(raw string) *Equation {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil
+	}
+
+	hostname := u.Hostname()
+	for _, r := range equationRenderers {
+		for _, h := range r.Hosts {
+			if hostname != h && !strings.HasSuffix(hostname, "."+h) {
+				continue
+			}
+
+			latex := strings.TrimSpace(r.LaTeX(u))
+			if len(latex) == 0 {
+				return nil
+			}
+
+			return &Equation{
+				LaTeX:   strings.TrimSpace(inlineEquationPattern.ReplaceAllString(latex, "")),
+				Display: !inlineEquationPattern.MatchString(latex),
+			}
+		}
+	}
+
+	return nil
+}
+
+// codecogsLaTeX decodes the html entities codecogs uses in the equation urls
+// and removes the leading rendering options, keeping \inline to mark inline
+// equations
+func codecogsLaTeX(latex string) string {
+	latex = strings.NewReplacer(
+		"&space;", " ",
+		"&plus;", "+",
+		"&hash;", "#",
+		"&amp;", "&",
+	).Replace(latex)
+
+	inline := false
+	for {
+		m := codecogsOptionPattern.FindStringSubmatch(latex)
+		if m == nil {
+			break
+		}
+
+		name := m[1]
+		if !codecogsSizes[name] && !strings.ContainsAny(name, "{_") {
+			// part of the equation
+			break
+		}
+
+		inline = inline || name == "inline"
+		latex = latex[len(m[0]):]
+	}
+
+	if inline {
+		return `\inline ` + latex
+	}
+
+	return latex
+}
+
+// renderEquation returns the markdown for the given equation, using the
+// dollar delimiters understood by KaTeX and MathJax.
+//
+// Hugo and Zola parse the equations as markdown before the math library sees
+// them, so backslashes, underscores and asterisks are escaped for them.
+// Jekyll's kramdown has its own math syntax, $$ both inline and as a block,
+// whose content is kept as is.
+func renderEquation(e *Equation, target string) string {
+	latex := e.LaTeX
+	delimiter := "$"
+	switch target {
+	case TargetHugo, TargetZola:
+		latex = equationEscaper.Replace(latex)
+	case TargetJekyll:
+		delimiter = "$$"
+	}
+
+	if e.Display {
+		return "\n\n$$\n" + latex + "\n$$\n\n"
+	}
+
+	return delimiter + latex + delimiter
+}
@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestEquationFromURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		latex   string
+		display bool
+	}{
+		{`https://latex.codecogs.com/gif.latex?\dpi{120}&space;E=mc^2`, "E=mc^2", true},
+		{`https://latex.codecogs.com/png.latex?\inline&space;\large&space;a_1&plus;b_2`, "a_1+b_2", false},
+		{"https://www.codecogs.com/eqnedit.php?latex=E=mc^2", "E=mc^2", true},
+		{"https://chart.googleapis.com/chart?cht=tx&chl=E%3Dmc%5E2", "E=mc^2", true},
+		{"https://render.githubusercontent.com/render/math?math=E%3Dmc%5E2", "E=mc^2", true},
+		{"https://math.now.sh?from=E%3Dmc%5E2", "E=mc^2", true},
+		{"https://i.upmath.me/svg/E%3Dmc%5E2", "E=mc^2", true},
+	}
+
+	for _, tt := range tests {
+		e := equationFromURL(tt.url)
+		if e == nil {
+			t.Errorf("equationFromURL(%q) = nil", tt.url)
+			continue
+		}
+
+		if e.LaTeX != tt.latex || e.Display != tt.display {
+			t.Errorf("equationFromURL(%q) = %q display %v, want %q display %v",
+				tt.url, e.LaTeX, e.Display, tt.latex, tt.display)
+		}
+	}
+
+	for _, raw := range []string{
+		"https://chart.googleapis.com/chart?cht=p3&chd=t:60,40",
+		"https://cdn-images-1.medium.com/max/800/1*abc.png",
+	} {
+		if e := equationFromURL(raw); e != nil {
+			t.Errorf("equationFromURL(%q) = %q, want nil", raw, e.LaTeX)
+		}
+	}
+}
+
+func TestRenderEquation(t *testing.T) {
+	latex := `a_1 * b_2 \\ c_{i}`
+	tests := []struct {
+		target  string
+		display bool
+		want    string
+	}{
+		{TargetHugo, false, `$a\_1 \* b\_2 \\\\ c\_{i}$`},
+		{TargetHugo, true, "\n\n$$\n" + `a\_1 \* b\_2 \\\\ c\_{i}` + "\n$$\n\n"},
+		{TargetZola, false, `$a\_1 \* b\_2 \\\\ c\_{i}$`},
+		{TargetJekyll, false, `$$a_1 * b_2 \\ c_{i}$$`},
+		{TargetJekyll, true, "\n\n$$\n" + latex + "\n$$\n\n"},
+		{TargetHTML, false, "$" + latex + "$"},
+	}
+
+	for _, tt := range tests {
+		got := renderEquation(&Equation{LaTeX: latex, Display: tt.display}, tt.target)
+		if got != tt.want {
+			t.Errorf("renderEquation(%s, display %v) = %q, want %q", tt.target, tt.display, got, tt.want)
+		}
+	}
+}
+
+func TestEquationRule(t *testing.T) {
+	fragment := `<p>where <span data-m2h-math="x_i"></span> is the input</p>` +
+		`<span data-m2h-math="\sum_{i=1}^n x_i" data-m2h-block></span>`
+	want := "where $x\\_i$ is the input\n\n$$\n\\\\sum\\_{i=1}^n x\\_i\n$$"
+	if got := convertHTML(t, Config{Target: TargetHugo}, fragment); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
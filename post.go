@@ -1,13 +1,14 @@
 package main
 
 import (
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
-	"net/http"
-	"os"
+	"html"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -17,12 +18,74 @@ type Post struct {
 	Date, Lastmod         string
 	Subtitle, Description string
 	Canonical, FullURL    string
+	OriginalURL           string
+	CanonicalURL          string
+	CanonicalURLKey       string
 	FeaturedImage         string
 	Images                []*Image
 	Tags                  []string
-	Draft                 bool
+	Aliases               []string
+	Highlights            []string
+	Draft, TOC, Math      bool
 	MdFilename            string
 	HTMLFileName          string
+
+	// code block languages that were inferred with low confidence
+	UncertainLanguages []*LanguageGuess
+
+	// indexes of the code blocks that have inline formatting
+	FormattedCodeBlocks []int
+}
+
+// A LanguageGuess records the language inferred for a code block of a post
+type LanguageGuess struct {
+	Block      int
+	Language   string
+	Confidence float64
+}
+
+// NormalizeLinks removes the query params matching the given patterns from
+// the links and the image urls of the post, and resolves the Medium redirect
+// wrappers to their target. The domain of the canonical link of the post is
+// treated as a Medium domain, since posts can be published on custom domains.
+// Returns the changed urls, as old => new.
+func (p *Post) NormalizeLinks(patterns []string) []string {
+	customHosts := make([]string, 0)
+	if u, err := url.Parse(p.DOM.Find(".p-canonical").AttrOr("href", "")); err == nil && len(u.Hostname()) > 0 {
+		customHosts = append(customHosts, u.Hostname())
+	}
+
+	changes := make([]string, 0)
+	normalize := func(s *goquery.Selection, attrs ...string) {
+		for _, attr := range attrs {
+			original, exists := s.Attr(attr)
+			if !exists {
+				continue
+			}
+
+			normalized := normalizeURL(original, patterns, customHosts)
+			if normalized == original {
+				continue
+			}
+
+			s.SetAttr(attr, normalized)
+
+			// data-href is the same as href
+			if attr != "data-href" {
+				changes = append(changes, fmt.Sprintf("%s => %s", original, normalized))
+			}
+		}
+	}
+
+	p.DOM.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		normalize(a, "href", "data-href")
+	})
+
+	p.DOM.Find("img[src]").Each(func(i int, img *goquery.Selection) {
+		normalize(img, "src")
+	})
+
+	return changes
 }
 
 // PruneMediumSpecifics removes unwanted elements in the HTML document
@@ -62,6 +125,456 @@ func (p *Post) PruneMediumSpecifics() {
 	})
 }
 
+// NormalizeHeadings changes the heading levels of the post content so that
+// the top most heading level used becomes the given level, and the rest of
+// the levels follow without gaps, capped at h6.
+//
+// h3, h4 => h2, h3 with level 2
+func (p *Post) NormalizeHeadings(top int) {
+	headings := p.DOM.Find("div.section-inner").Find("h1, h2, h3, h4, h5, h6")
+	if headings.Length() == 0 {
+		return
+	}
+
+	// collect the levels in use
+	used := make([]bool, 7)
+	headings.Each(func(i int, h *goquery.Selection) {
+		used[headingLevel(h)] = true
+	})
+
+	// map each level in use to the next level starting from top
+	levels := make([]int, 7)
+	next := top
+	for l := 1; l <= 6; l++ {
+		if !used[l] {
+			continue
+		}
+
+		levels[l] = next
+		if next < 6 {
+			next++
+		}
+	}
+
+	headings.Each(func(i int, h *goquery.Selection) {
+		h.Get(0).Data = fmt.Sprintf("h%d", levels[headingLevel(h)])
+	})
+}
+
+// ContinueInterruptedLists sets the start number of ordered lists that are
+// only separated from a previous ordered list by images, embeds or code
+// blocks, so that the numbering continues as shown in Medium. Lists that
+// are separated by any other content start from their own start number.
+func (p *Post) ContinueInterruptedLists() {
+	p.DOM.Find("ol").Each(func(i int, ol *goquery.Selection) {
+		prev := ol.Prev()
+		for goquery.NodeName(prev) == "figure" || goquery.NodeName(prev) == "pre" {
+			prev = prev.Prev()
+		}
+
+		// adjacent lists are merged in markdown anyway
+		if goquery.NodeName(prev) != "ol" || prev.IsSelection(ol.Prev()) {
+			return
+		}
+
+		// the previous list is already numbered, since the lists are processed in order
+		start, err := strconv.Atoi(prev.AttrOr("start", "1"))
+		if err != nil {
+			start = 1
+		}
+
+		ol.SetAttr("start", strconv.Itoa(start+prev.Children().Filter("li").Length()))
+	})
+}
+
+// AnnotateHeadingAnchors marks each heading of the post content with a stable
+// anchor generated from the heading text, unique within the post
+func (p *Post) AnnotateHeadingAnchors() {
+	used := make(map[string]int)
+	p.DOM.Find("div.section-inner").Find("h1, h2, h3, h4, h5, h6").Each(func(i int, h *goquery.Selection) {
+		anchor := generateSlug(strings.TrimSpace(h.Text()))
+		if len(anchor) == 0 {
+			anchor = "section"
+		}
+
+		// repeated headings get a numbered suffix, skipping the suffixes taken by other headings
+		if count, exists := used[anchor]; exists {
+			base := anchor
+			for exists {
+				count++
+				anchor = fmt.Sprintf("%s-%d", base, count)
+				_, exists = used[anchor]
+			}
+
+			used[base] = count
+		}
+
+		used[anchor] = 0
+
+		h.SetAttr(HeadingAnchorAttr, anchor)
+	})
+}
+
+// RewriteAnchorLinks changes the links to the Medium anchors of the headings
+// in the post, to point to the anchors generated for the headings. The links
+// can be relative (#3f51) or self links to the post (/slug-f57f5c1a492#3f51).
+func (p *Post) RewriteAnchorLinks() {
+	anchors := make(map[string]string)
+	p.DOM.Find("[" + HeadingAnchorAttr + "][name]").Each(func(i int, h *goquery.Selection) {
+		anchors[h.AttrOr("name", "")] = h.AttrOr(HeadingAnchorAttr, "")
+	})
+
+	if len(anchors) == 0 {
+		return
+	}
+
+	p.DOM.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		u, err := url.Parse(a.AttrOr("href", ""))
+		if err != nil {
+			return
+		}
+
+		anchor, exists := anchors[u.Fragment]
+		if !exists {
+			return
+		}
+
+		// only the links pointing to this post
+		relative := len(u.Host) == 0 && len(u.Path) == 0
+		if !relative && (len(p.Canonical) == 0 || lastPathSegment(u.Path) != p.Canonical) {
+			return
+		}
+
+		u.Fragment = anchor
+		a.SetAttr("href", u.String())
+		a.SetAttr("data-href", u.String())
+	})
+}
+
+// TableOfContents renders a markdown list of links to the anchored headings
+// of the post, nested by the heading levels
+func (p *Post) TableOfContents() string {
+	headings := p.DOM.Find("[" + HeadingAnchorAttr + "]")
+	if headings.Length() == 0 {
+		return ""
+	}
+
+	top := 6
+	headings.Each(func(i int, h *goquery.Selection) {
+		if l := headingLevel(h); l < top {
+			top = l
+		}
+	})
+
+	toc := make([]string, 0)
+	headings.Each(func(i int, h *goquery.Selection) {
+		toc = append(toc, fmt.Sprintf(
+			"%s- [%s](#%s)",
+			strings.Repeat("  ", headingLevel(h)-top),
+			strings.TrimSpace(h.Text()),
+			h.AttrOr(HeadingAnchorAttr, "")))
+	})
+
+	return strings.Join(toc, "\n")
+}
+
+// PopulateHighlights collects the text of the highlights and quote
+// annotations in the post, in the order they appear
+func (p *Post) PopulateHighlights() {
+	seen := make(map[string]bool)
+	p.DOM.Find("mark, .markup--highlight, .markup--quote").Each(func(i int, selection *goquery.Selection) {
+		text := strings.TrimSpace(selection.Text())
+		if len(text) == 0 || seen[text] {
+			return
+		}
+
+		seen[text] = true
+		p.Highlights = append(p.Highlights, text)
+	})
+}
+
+// ConvertLinkCards replaces the Medium link preview cards (mixtape embeds)
+// with placeholders that keep the link, title, description and the thumbnail,
+// so that they can be rendered as link cards. The thumbnail is added as an img
+// element to be downloaded with the rest of the images.
+func (p *Post) ConvertLinkCards() {
+	//<div class="graf graf--mixtapeEmbed">
+	// <a href="https://medium.com/..." class="markup--anchor markup--mixtapeEmbed-anchor">
+	//  <strong class="markup--strong markup--mixtapeEmbed-strong">Title</strong><br>
+	//  <em class="markup--em markup--mixtapeEmbed-em">Description</em>medium.com</a>
+	// <a href="https://medium.com/..." class="js-mixtapeImage mixtapeImage u-ignoreBlock"
+	//  data-thumbnail-img-id="0*abc.jpeg" style="background-image: url(https://cdn-images-1.medium.com/...);"></a>
+	//</div>
+	p.DOM.Find(".graf--mixtapeEmbed").Each(func(i int, mixtape *goquery.Selection) {
+		anchor := mixtape.Find("a.markup--mixtapeEmbed-anchor")
+		if anchor.Length() == 0 {
+			return
+		}
+
+		card := fmt.Sprintf(
+			"<div class=\"%s\" data-href=\"%s\" data-title=\"%s\" data-description=\"%s\">",
+			LinkCardClass,
+			html.EscapeString(anchor.AttrOr("href", "")),
+			html.EscapeString(strings.TrimSpace(anchor.Find("strong").Text())),
+			html.EscapeString(strings.TrimSpace(anchor.Find("em").Text())))
+
+		thumbnail := mixtapeThumbnail(mixtape.Find("a.mixtapeImage"))
+		if len(thumbnail) > 0 {
+			card += fmt.Sprintf("<img src=\"%s\">", html.EscapeString(thumbnail))
+		}
+
+		mixtape.ReplaceWithHtml(card + "</div>")
+	})
+}
+
+// AnnotateCodeLanguages determines the language of each code block in the
+// post and marks the first pre element of the block with it, so that it can
+// be used when rendering the code block. Consecutive pre elements are
+// considered to be a single code block.
+//
+// The languages in the given PostOverride take precedence. If guess is true,
+// the languages of the rest of the code blocks are inferred from the code.
+// Low confidence guesses are only recorded in the Post, and the code block is
+// left without a language.
+func (p *Post) AnnotateCodeLanguages(override *PostOverride, guess bool) {
+	p.eachCodeBlock(func(block int, pres *goquery.Selection) {
+		lang, overridden := override.CodeLanguages[block]
+		if !overridden && guess {
+			code := ""
+			pres.Each(func(i int, pre *goquery.Selection) {
+				if i > 0 {
+					code += "\n\n"
+				}
+
+				readCodeContent(pre, &code)
+			})
+
+			var confidence float64
+			lang, confidence = classifyLanguage(code)
+			if len(lang) > 0 && confidence < LanguageConfidenceThreshold {
+				p.UncertainLanguages = append(p.UncertainLanguages, &LanguageGuess{
+					Block:      block,
+					Language:   lang,
+					Confidence: confidence,
+				})
+				lang = ""
+			}
+		}
+
+		if len(lang) > 0 {
+			pres.First().SetAttr(CodeLanguageAttr, lang)
+		}
+	})
+}
+
+// AnnotateCodeFormatting finds the code blocks with inline formatting (bold,
+// italic text and links) which will be lost when rendered as a markdown code
+// block, and records them in the Post. If preserve is true, the code blocks
+// are marked to be rendered as HTML code blocks, keeping the formatting.
+func (p *Post) AnnotateCodeFormatting(preserve bool) {
+	p.eachCodeBlock(func(block int, pres *goquery.Selection) {
+		if pres.Find("strong, b, em, i, a").Length() == 0 {
+			return
+		}
+
+		p.FormattedCodeBlocks = append(p.FormattedCodeBlocks, block)
+		if preserve {
+			pres.First().SetAttr(CodeHTMLAttr, "true")
+		}
+	})
+}
+
+// eachCodeBlock iterates the code blocks of the post, calling the given
+// function with the index of the code block and its pre elements. Consecutive
+// pre elements are considered to be a single code block, as done when
+// rendering.
+func (p *Post) eachCodeBlock(f func(block int, pres *goquery.Selection)) {
+	block := 0
+	p.DOM.Find("pre").Each(func(i int, pre *goquery.Selection) {
+		// the rest of the consecutive pre blocks are collected to the first one
+		if goquery.NodeName(pre.Prev()) == "pre" {
+			return
+		}
+
+		f(block, pre.AddSelection(pre.NextUntil(":not(pre)")))
+		block++
+	})
+}
+
+// ConvertEquationImages replaces the images of equations rendered by the
+// known equation renderers with the LaTeX source of the equation, recovered
+// from the image url or the url the image links to. Equations shown as a
+// figure are rendered as display equations, keeping the caption.
+func (p *Post) ConvertEquationImages() {
+	p.DOM.Find("img").Each(func(i int, img *goquery.Selection) {
+		eq := equationFromURL(img.AttrOr("src", ""))
+		if eq == nil {
+			// https://www.codecogs.com/eqnedit.php?latex=E=mc^2
+			eq = equationFromURL(img.Closest("a").AttrOr("href", ""))
+		}
+
+		if eq == nil {
+			return
+		}
+
+		attrs := fmt.Sprintf(`%s="%s"`, EquationAttr, html.EscapeString(eq.LaTeX))
+		if figure := img.Closest("figure"); figure.Length() > 0 {
+			marker := fmt.Sprintf(`<span %s %s></span>`, attrs, EquationDisplayAttr)
+			if caption := figure.Find("figcaption"); caption.Length() > 0 {
+				captionHTML, _ := caption.Html()
+				marker = fmt.Sprintf("%s<p>%s</p>", marker, captionHTML)
+			}
+
+			figure.ReplaceWithHtml(marker)
+		} else if a := img.Closest("a"); a.Length() > 0 {
+			a.ReplaceWithHtml(fmt.Sprintf(`<span %s></span>`, attrs))
+		} else {
+			img.ReplaceWithHtml(fmt.Sprintf(`<span %s></span>`, attrs))
+		}
+
+		p.Math = true
+	})
+}
+
+// ConvertFootnotes finds the superscript footnote markers in the post that
+// have a matching footnote paragraph later in the post, and marks them so that
+// they are rendered as markdown footnotes. The footnote paragraphs start with
+// the marker, as a superscript or as text ([1], 1., 1) or 1:), and the marker
+// is removed from them. Paragraphs numbered as 1. 1) or 1: are only taken as
+// footnotes at the end of the post. A Notes, Footnotes or References heading
+// right before the footnotes is removed, since the footnotes are rendered at
+// the end of the post.
+func (p *Post) ConvertFootnotes() {
+	markers := make(map[string][]*goquery.Selection)
+	definitions := make(map[string]*goquery.Selection)
+	trailing := p.trailingFootnoteParagraphs()
+
+	p.DOM.Find("sup, p").Each(func(i int, s *goquery.Selection) {
+		if goquery.NodeName(s) == "p" {
+			// numbered paragraphs are only taken as footnotes at the end of the post, otherwise x<sup>2</sup>
+			// followed by a "2. step" paragraph would be converted
+			label, numbered := footnoteDefinitionLabel(s)
+			if numbered && !trailing.IsSelection(s) {
+				return
+			}
+
+			// only footnotes after the markers are considered, the last one wins
+			if len(markers[label]) > 0 {
+				definitions[label] = s
+			}
+
+			return
+		}
+
+		m := footnoteLabel.FindStringSubmatch(strings.TrimSpace(s.Text()))
+		if m == nil {
+			return
+		}
+
+		// the marker of a footnote paragraph
+		if par := s.Closest("p"); par.Length() > 0 && par.Children().First().IsSelection(s) {
+			if label, _ := footnoteDefinitionLabel(par); label == m[1] {
+				return
+			}
+		}
+
+		markers[m[1]] = append(markers[m[1]], s)
+	})
+
+	notesHeading := regexp.MustCompile(`(?i)^((foot)?notes|references):?$`)
+	for label, def := range definitions {
+		for _, sup := range markers[label] {
+			sup.SetAttr(FootnoteAttr, label)
+		}
+
+		// remove the marker from the footnote
+		if sup := def.Children().First(); goquery.NodeName(sup) == "sup" &&
+			strings.HasPrefix(strings.TrimSpace(def.Text()), strings.TrimSpace(sup.Text())) {
+			sup.Remove()
+		} else {
+			trimLeadingText(def, footnoteDefinitionPrefix)
+		}
+
+		def.SetAttr(FootnoteTextAttr, label)
+
+		if prev := def.Prev(); prev.Is("h1, h2, h3, h4, h5, h6") && notesHeading.MatchString(strings.TrimSpace(prev.Text())) {
+			prev.Remove()
+		}
+	}
+}
+
+// footnoteLabel matches the superscript footnote markers, 1 or [1]
+var footnoteLabel = regexp.MustCompile(`^\[?(\d+)\]?$`)
+
+// footnoteDefinitionPrefix matches the markers at the beginning of a footnote
+// paragraph written as text, [1], 1., 1) or 1:
+var footnoteDefinitionPrefix = regexp.MustCompile(`^\s*(?:\[(\d+)\]|(\d+)[.):])\s*`)
+
+// footnoteDefinitionLabel returns the label of the footnote marker the given
+// paragraph starts with, an empty string if the paragraph doesn't start with a
+// marker. The second return value reports whether the marker is a plain
+// number (1. 1) or 1:), which numbered steps are written with too.
+func footnoteDefinitionLabel(par *goquery.Selection) (string, bool) {
+	text := strings.TrimSpace(par.Text())
+
+	// <p><sup>1</sup> footnote</p>
+	if sup := par.Children().First(); goquery.NodeName(sup) == "sup" && strings.HasPrefix(text, strings.TrimSpace(sup.Text())) {
+		if m := footnoteLabel.FindStringSubmatch(strings.TrimSpace(sup.Text())); m != nil {
+			return m[1], false
+		}
+	}
+
+	// <p>[1] footnote</p>
+	if m := footnoteDefinitionPrefix.FindStringSubmatch(text); m != nil && len(text) > len(m[0]) {
+		return m[1] + m[2], len(m[2]) > 0
+	}
+
+	return "", false
+}
+
+// trailingFootnoteParagraphs returns the paragraphs at the end of the post
+// body that all start with a footnote marker, along with any empty paragraphs
+// among them
+func (p *Post) trailingFootnoteParagraphs() *goquery.Selection {
+	body := p.DOM.Find("section[data-field='body']")
+	if body.Length() == 0 {
+		body = p.DOM.Selection
+	}
+
+	pars := body.Find("p")
+	start := pars.Length()
+	for ; start > 0; start-- {
+		par := pars.Eq(start - 1)
+		if label, _ := footnoteDefinitionLabel(par); len(label) == 0 && len(strings.TrimSpace(par.Text())) > 0 {
+			break
+		}
+	}
+
+	return pars.Slice(start, pars.Length())
+}
+
+// trimLeadingText removes the given pattern from the first non empty text
+// node of the given element
+func trimLeadingText(s *goquery.Selection, pattern *regexp.Regexp) bool {
+	trimmed := false
+	s.Contents().EachWithBreak(func(i int, c *goquery.Selection) bool {
+		if goquery.NodeName(c) != "#text" {
+			trimmed = trimLeadingText(c, pattern)
+			return !trimmed && len(strings.TrimSpace(c.Text())) == 0
+		}
+
+		if len(strings.TrimSpace(c.Text())) == 0 {
+			return true
+		}
+
+		c.Nodes[0].Data = pattern.ReplaceAllString(c.Nodes[0].Data, "")
+		trimmed = true
+		return false
+	})
+
+	return trimmed
+}
+
 // NewImage creates an Image struct based on the given DOM element
 func (p *Post) NewImage(dom *goquery.Selection, i int) (*Image, error) {
 	imgSrc, exists := dom.Attr("src")
@@ -83,13 +596,42 @@ func (p *Post) NewImage(dom *goquery.Selection, i int) (*Image, error) {
 	img := &Image{
 		MediumURL: imgSrc,
 		FileName:  imgFilename,
+		Index:     i,
+		element:   dom,
 	}
 
+	// medium records the original dimensions of the image
+	img.Width, _ = strconv.Atoi(dom.AttrOr("data-width", ""))
+	img.Height, _ = strconv.Atoi(dom.AttrOr("data-height", ""))
+	_, img.Featured = dom.Attr("data-is-featured")
+	img.Thumbnail = dom.Parent().HasClass(LinkCardClass)
+
 	// all successful, attach a reference
 	p.Images = append(p.Images, img)
 	return img, nil
 }
 
+// SetMdFilename generates the markdown filename of the Post from the date
+// and the title, <date>_<slug>.md or draft__<slug>.md for drafts
+func (p *Post) SetMdFilename() {
+	// 1. filename prefix, usually <date>_
+	//datetime ISO 2018-09-25T14:13:46.823Z
+	//we only keep the date for simplicity
+	createdDate := strings.Split(p.Date, "T")[0]
+	prefix := createdDate
+
+	// drafts get "draft_" as the prefix
+	if p.Draft {
+		prefix = DraftPrefix
+	}
+
+	// 2. clean up the title
+	slug := generateSlug(p.Title)
+
+	// 3. collect them to the filename - done
+	p.MdFilename = prefix + "_" + slug + MarkdownFileExtension
+}
+
 // GetFileNamePrefix returns just the markdown filename without the extension
 // of a given Post. This can be used for naming related artifacts.
 func (p *Post) GetFileNamePrefix() (string, error) {
@@ -109,44 +651,87 @@ func (p *Post) GetFileNamePrefix() (string, error) {
 func (p *Post) SetCanonicalName() {
 	canonical := p.DOM.Find(".p-canonical")
 	if canonical != nil {
-		var exists bool
 		//https://coder.today/a-b-tests-developers-manual-f57f5c1a492
-		p.FullURL, exists = canonical.Attr("href")
-		if exists && len(p.FullURL) > 0 {
-			pieces := strings.Split(p.FullURL, "/")
-			if len(pieces) > 2 {
-				//a-b-tests-developers-manual-f57f5c1a492
-				p.Canonical = pieces[len(pieces)-1] //we only need the last part
-			}
+		p.SetFullURL(canonical.AttrOr("href", ""))
+	}
+}
+
+// SetFullURL sets the url of the given Post on Medium, along with the last
+// part of it used as the canonical name
+func (p *Post) SetFullURL(fullURL string) {
+	p.FullURL = fullURL
+	if len(p.FullURL) > 0 {
+		pieces := strings.Split(p.FullURL, "/")
+		if len(pieces) > 2 {
+			//a-b-tests-developers-manual-f57f5c1a492
+			p.Canonical = pieces[len(pieces)-1] //we only need the last part
 		}
 	}
 }
 
 // FixSelfLinks searches for links in the page that refers to other posts by
-// the same author (provided username) and changes them to point to local
-// relative URLs so that they will point to the Hugo hosted pages
-func (p *Post) FixSelfLinks(username string) error {
+// the same author and changes them to point to the paths of the converted
+// posts on the new site. The links are matched with the given PostIndex by the
+// Medium post id, so links through custom domains, publications and Medium
+// short links are fixed as well. With the ref and relref link modes, the links
+// are rendered as Hugo shortcodes instead of paths, except for link cards which
+// may be rendered as shortcode parameters. Links to posts by the same author
+// (provided username) that aren't in the index are changed to relative URLs,
+// which are covered by the aliases of the Hugo hosted pages.
+func (p *Post) FixSelfLinks(username string, index *PostIndex, linkMode string) error {
 	username = strings.TrimSpace(username)
-	if len(username) == 0 {
-		return fmt.Errorf("invalid username")
-	}
-
 	mediumBaseUrl := fmt.Sprintf("%s/@%s", "https://medium.com", username)
+	ownID := mediumPostID(p.FullURL)
 
-	anchors := p.DOM.Find(".markup--anchor")
-	if anchors.Length() != 0 {
-		anchors.Each(func(i int, aDomElement *goquery.Selection) {
-			original, has := aDomElement.Attr("href")
-			if !has {
-				return
+	fix := func(original, mode string) (string, bool) {
+		if id, post, found := index.Lookup(original); found {
+			u, err := url.Parse(original)
+			if err != nil {
+				return "", false
+			}
+
+			// keep the links to the anchors of this post relative, so they can be rewritten to the heading anchors
+			if id == ownID && len(u.Fragment) > 0 {
+				return "#" + u.Fragment, true
+			}
+
+			fragment := ""
+			if len(u.Fragment) > 0 {
+				fragment = "#" + u.Fragment
 			}
 
-			if strings.Contains(original, mediumBaseUrl) {
-				replaced := strings.TrimPrefix(original, mediumBaseUrl)
-				aDomElement.SetAttr("href", replaced)
-				aDomElement.SetAttr("data-href", replaced)
+			// {{< relref "2018-09-25_a-b-tests-developers-manual.md#3f51" >}}
+			if mode == InternalLinksRef || mode == InternalLinksRelref {
+				return fmt.Sprintf(`{{< %s "%s%s" >}}`, mode, post.MdFilename, fragment), true
 			}
-		})
+
+			return post.Path + fragment, true
+		}
+
+		if len(username) > 0 && strings.Contains(original, mediumBaseUrl) {
+			return strings.TrimPrefix(original, mediumBaseUrl), true
+		}
+
+		return "", false
+	}
+
+	// the footer links to the post on Medium
+	p.DOM.Find("section[data-field='body'] a[href]").Each(func(i int, aDomElement *goquery.Selection) {
+		if replaced, fixed := fix(aDomElement.AttrOr("href", ""), linkMode); fixed {
+			aDomElement.SetAttr("href", replaced)
+			aDomElement.SetAttr("data-href", replaced)
+		}
+	})
+
+	// link card placeholders only keep the link in data-href
+	p.DOM.Find("." + LinkCardClass).Each(func(i int, card *goquery.Selection) {
+		if replaced, fixed := fix(card.AttrOr("data-href", ""), InternalLinksPath); fixed {
+			card.SetAttr("data-href", replaced)
+		}
+	})
+
+	if len(username) == 0 {
+		return fmt.Errorf("invalid username")
 	}
 
 	return nil
@@ -160,12 +745,7 @@ func (p *Post) PopulateTags() error {
 		return nil
 	}
 
-	skipTLS := strings.ToLower(os.Getenv("ALLOW_INSECURE")) == "true"
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLS},
-	}
-
-	client := &http.Client{Transport: tr}
+	client := newHTTPClient()
 
 	res, err := client.Get(p.FullURL)
 	if err != nil {
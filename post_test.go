@@ -0,0 +1,421 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestAnnotateCodeFormatting(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{CodeFormattingDrop, "code-formatting.md"},
+		{CodeFormattingReport, "code-formatting.md"},
+		{CodeFormattingHTML, "code-formatting-html.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			content, err := ioutil.ReadFile(filepath.Join("testdata", "code-formatting.html"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(string(content)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf := Config{Target: TargetHugo, CodeFormatting: tt.mode}
+			p := &Post{DOM: dom}
+			p.AnnotateCodeFormatting(conf.CodeFormatting == CodeFormattingHTML)
+
+			if !reflect.DeepEqual(p.FormattedCodeBlocks, []int{0}) {
+				t.Errorf("got formatted code blocks %v, want [0]", p.FormattedCodeBlocks)
+			}
+
+			got := strings.TrimSpace(newMarkdownConverter(&conf).Convert(dom.Find("body")))
+			if want := readGolden(t, tt.want); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// mixtapeEmbed is a Medium link preview card as found in the export
+const mixtapeEmbed = `<div class="graf graf--mixtapeEmbed">` +
+	`<a href="https://example.com/post?a=1&amp;b=2" class="markup--anchor markup--mixtapeEmbed-anchor">` +
+	`<strong class="markup--strong markup--mixtapeEmbed-strong">The "Post"</strong><br>` +
+	`<em class="markup--em markup--mixtapeEmbed-em">A description</em>example.com</a>` +
+	`<a href="https://example.com/post" class="js-mixtapeImage mixtapeImage u-ignoreBlock" data-thumbnail-img-id="0*abc.jpeg" ` +
+	`style="background-image: url(https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg);"></a>` +
+	`</div>`
+
+func TestConvertLinkCards(t *testing.T) {
+	tests := []struct {
+		name      string
+		thumbnail string
+		want      string
+	}{
+		{
+			name:      "background image",
+			thumbnail: `style="background-image: url('https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg');"`,
+			want:      "https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg",
+		},
+		{
+			name:      "thumbnail id",
+			thumbnail: `data-thumbnail-img-id="0*xyz.png"`,
+			want:      "https://cdn-images-1.medium.com/fit/c/160/160/0*xyz.png",
+		},
+		{
+			name: "no thumbnail",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(
+				`<div class="graf graf--mixtapeEmbed">` +
+					`<a href="https://example.com/post" class="markup--anchor markup--mixtapeEmbed-anchor">` +
+					`<strong class="markup--strong markup--mixtapeEmbed-strong"> Title </strong><br>` +
+					`<em class="markup--em markup--mixtapeEmbed-em">Description</em>example.com</a>` +
+					`<a href="https://example.com/post" class="js-mixtapeImage mixtapeImage u-ignoreBlock" ` + tt.thumbnail + `></a>` +
+					`</div>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := &Post{DOM: dom}
+			p.ConvertLinkCards()
+
+			card := dom.Find("." + LinkCardClass)
+			if card.Length() != 1 || dom.Find(".graf--mixtapeEmbed").Length() != 0 {
+				t.Fatalf("the link preview was not replaced with a card placeholder")
+			}
+
+			for attr, want := range map[string]string{
+				"data-href":        "https://example.com/post",
+				"data-title":       "Title",
+				"data-description": "Description",
+			} {
+				if got := card.AttrOr(attr, ""); got != want {
+					t.Errorf("got %s %q, want %q", attr, got, want)
+				}
+			}
+
+			if got := card.Find("img").AttrOr("src", ""); got != tt.want {
+				t.Errorf("got thumbnail %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkCardRendering(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		linkCards string
+		want      string
+	}{
+		{
+			name:      "none",
+			target:    TargetHugo,
+			linkCards: LinkCardsNone,
+			want:      `[The "Post"](https://example.com/post?a=1&b=2)`,
+		},
+		{
+			name:      "hugo shortcode",
+			target:    TargetHugo,
+			linkCards: LinkCardsShortcode,
+			want: `{{< link-card href="https://example.com/post?a=1&b=2" title="The \"Post\"" description="A description" ` +
+				`image="https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg" >}}`,
+		},
+		{
+			name:      "jekyll shortcode",
+			target:    TargetJekyll,
+			linkCards: LinkCardsShortcode,
+			want: `{% include link-card.html href="https://example.com/post?a=1&b=2" title="The \"Post\"" description="A description" ` +
+				`image="https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg" %}`,
+		},
+		{
+			name:      "zola shortcode",
+			target:    TargetZola,
+			linkCards: LinkCardsShortcode,
+			want: `{{ link_card(href="https://example.com/post?a=1&b=2", title="The \"Post\"", description="A description", ` +
+				`image="https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg") }}`,
+		},
+		{
+			name:      "shortcode without shortcodes in the target",
+			target:    TargetHTML,
+			linkCards: LinkCardsShortcode,
+			want: `<div class="link-card"><a href="https://example.com/post?a=1&amp;b=2">` +
+				`<img src="https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg" alt="">` +
+				`<strong>The &#34;Post&#34;</strong><br><em>A description</em></a></div>`,
+		},
+		{
+			name:      "html",
+			target:    TargetHugo,
+			linkCards: LinkCardsHTML,
+			want: `<div class="link-card"><a href="https://example.com/post?a=1&amp;b=2">` +
+				`<img src="https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg" alt="">` +
+				`<strong>The &#34;Post&#34;</strong><br><em>A description</em></a></div>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(mixtapeEmbed))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conf := Config{Target: tt.target, LinkCards: tt.linkCards}
+			p := &Post{DOM: dom}
+			if conf.LinkCards != LinkCardsNone {
+				p.ConvertLinkCards()
+			}
+			p.PruneMediumSpecifics()
+
+			got := strings.TrimSpace(newMarkdownConverter(&conf).Convert(dom.Find("body")))
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeHeadings(t *testing.T) {
+	tests := []struct {
+		name string
+		top  int
+		in   []string
+		want []string
+	}{
+		{"medium headings", 2, []string{"h3", "h4", "h3"}, []string{"h2", "h3", "h2"}},
+		{"gaps are closed", 2, []string{"h1", "h4", "h6", "h4"}, []string{"h2", "h3", "h4", "h3"}},
+		{"shifted up", 1, []string{"h3", "h5"}, []string{"h1", "h2"}},
+		{"capped at h6", 5, []string{"h1", "h2", "h3"}, []string{"h5", "h6", "h6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := ""
+			for i, h := range tt.in {
+				content += fmt.Sprintf("<%s>heading %d</%s>", h, i, h)
+			}
+
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(
+				`<h1 class="p-name">title</h1><div class="section-inner">` + content + `</div>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p := &Post{DOM: dom}
+			p.NormalizeHeadings(tt.top)
+
+			got := make([]string, 0)
+			dom.Find("div.section-inner").Children().Each(func(i int, h *goquery.Selection) {
+				got = append(got, goquery.NodeName(h))
+			})
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			// only the post content is changed
+			if dom.Find("h1.p-name").Length() != 1 {
+				t.Errorf("the title heading was changed")
+			}
+		})
+	}
+}
+
+func TestAnnotateHeadingAnchors(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="section-inner">` +
+		`<h3>Getting Started</h3><h4>Setup</h4><h3>Setup 1</h3><h4>Setup</h4><h4>Setup</h4><h3>!!!</h3><h3>???</h3>` +
+		`</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom}
+	p.AnnotateHeadingAnchors()
+
+	got := make([]string, 0)
+	dom.Find("h3, h4").Each(func(i int, h *goquery.Selection) {
+		got = append(got, h.AttrOr(HeadingAnchorAttr, ""))
+	})
+
+	want := []string{"getting-started", "setup", "setup-1", "setup-2", "setup-3", "section", "section-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got anchors %v, want %v", got, want)
+	}
+}
+
+func TestTableOfContents(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="section-inner">` +
+		`<h3>Intro</h3><p>text</p><h4>Install</h4><h4>Run</h4><h3>Intro</h3>` +
+		`</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom}
+	if toc := p.TableOfContents(); toc != "" {
+		t.Errorf("got %q before the headings are anchored, want no table of contents", toc)
+	}
+
+	p.AnnotateHeadingAnchors()
+
+	want := "- [Intro](#intro)\n  - [Install](#install)\n  - [Run](#run)\n- [Intro](#intro-1)"
+	if got := p.TableOfContents(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRewriteAnchorLinks(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<div class="section-inner">` +
+		`<h3 name="3f51" data-m2h-anchor="getting-started">Getting Started</h3>` +
+		`<p><a href="#3f51">relative</a>` +
+		`<a href="https://medium.com/@author/a-post-f57f5c1a492#3f51">self link</a>` +
+		`<a href="https://medium.com/@author/other-post-a1b2c3d4e5f#3f51">other post</a>` +
+		`<a href="#5b8a">paragraph</a></p>` +
+		`</div>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Post{DOM: dom, Canonical: "a-post-f57f5c1a492"}
+	p.RewriteAnchorLinks()
+
+	want := []string{
+		"#getting-started",
+		"https://medium.com/@author/a-post-f57f5c1a492#getting-started",
+		"https://medium.com/@author/other-post-a1b2c3d4e5f#3f51",
+		"#5b8a",
+	}
+
+	got := make([]string, 0)
+	dom.Find("a").Each(func(i int, a *goquery.Selection) {
+		got = append(got, a.AttrOr("href", ""))
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v, want %v", got, want)
+	}
+}
+
+func TestFixSelfLinks(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<section data-field="body">` +
+		`<p><a href="https://medium.com/@author/this-post-a1b2c3d4e5f6#5b8a">own anchor</a>` +
+		`<a href="https://blog.example.com/old-title-f57f5c1a492">renamed</a>` +
+		`<a href="https://medium.com/some-publication/a-b-tests-f57f5c1a492#3f51">with anchor</a>` +
+		`<a href="https://medium.com/@author/not-converted-0a1b2c3d4e5f">not indexed</a>` +
+		`<a href="https://example.com/about">external</a></p>` +
+		`<div class="m2h-link-card" data-href="https://medium.com/p/f57f5c1a492"></div>` +
+		`</section>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx := newPostIndex()
+	idx.Add("f57f5c1a492", &IndexedPost{Path: "/post/2018-09-25_a-b-tests/", MdFilename: "2018-09-25_a-b-tests.md"})
+	idx.Add("a1b2c3d4e5f6", &IndexedPost{Path: "/post/2019-01-10_this-post/", MdFilename: "2019-01-10_this-post.md"})
+
+	p := &Post{DOM: dom, FullURL: "https://medium.com/@author/this-post-a1b2c3d4e5f6"}
+	if err := p.FixSelfLinks("author", idx, InternalLinksPath); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []string{
+		"#5b8a",
+		"/post/2018-09-25_a-b-tests/",
+		"/post/2018-09-25_a-b-tests/#3f51",
+		"/not-converted-0a1b2c3d4e5f",
+		"https://example.com/about",
+	}
+
+	got := make([]string, 0)
+	dom.Find("a").Each(func(i int, a *goquery.Selection) {
+		got = append(got, a.AttrOr("href", ""))
+	})
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got links %v, want %v", got, want)
+	}
+
+	if card := dom.Find(".m2h-link-card").AttrOr("data-href", ""); card != "/post/2018-09-25_a-b-tests/" {
+		t.Errorf("got link card %q, want /post/2018-09-25_a-b-tests/", card)
+	}
+}
+
+func TestFixSelfLinksShortcodes(t *testing.T) {
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{
+			mode: InternalLinksRef,
+			want: []string{
+				"#5b8a",
+				`{{< ref "2018-09-25_a-b-tests.md" >}}`,
+				`{{< ref "2018-09-25_a-b-tests.md#3f51" >}}`,
+				"/not-converted-0a1b2c3d4e5f",
+			},
+		},
+		{
+			mode: InternalLinksRelref,
+			want: []string{
+				"#5b8a",
+				`{{< relref "2018-09-25_a-b-tests.md" >}}`,
+				`{{< relref "2018-09-25_a-b-tests.md#3f51" >}}`,
+				"/not-converted-0a1b2c3d4e5f",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<section data-field="body">` +
+				`<p><a href="https://medium.com/@author/this-post-a1b2c3d4e5f6#5b8a">own anchor</a>` +
+				`<a href="https://blog.example.com/old-title-f57f5c1a492">renamed</a>` +
+				`<a href="https://medium.com/some-publication/a-b-tests-f57f5c1a492#3f51">with anchor</a>` +
+				`<a href="https://medium.com/@author/not-converted-0a1b2c3d4e5f">not indexed</a></p>` +
+				`<div class="m2h-link-card" data-href="https://medium.com/p/f57f5c1a492"></div>` +
+				`</section>`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			idx := newPostIndex()
+			idx.Add("f57f5c1a492", &IndexedPost{Path: "/post/2018-09-25_a-b-tests/", MdFilename: "2018-09-25_a-b-tests.md"})
+			idx.Add("a1b2c3d4e5f6", &IndexedPost{Path: "/post/2019-01-10_this-post/", MdFilename: "2019-01-10_this-post.md"})
+
+			p := &Post{DOM: dom, FullURL: "https://medium.com/@author/this-post-a1b2c3d4e5f6"}
+			if err := p.FixSelfLinks("author", idx, tt.mode); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			got := make([]string, 0)
+			dom.Find("a").Each(func(i int, a *goquery.Selection) {
+				got = append(got, a.AttrOr("href", ""))
+			})
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got links %v, want %v", got, tt.want)
+			}
+
+			// link cards can't hold a shortcode, they keep the path
+			if card := dom.Find(".m2h-link-card").AttrOr("data-href", ""); card != "/post/2018-09-25_a-b-tests/" {
+				t.Errorf("got link card %q, want /post/2018-09-25_a-b-tests/", card)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// A Redirect is a permanent redirect from an old Medium path to the path of
+// the converted post on the new site
+type Redirect struct {
+	From, To string
+}
+
+// A RedirectFormat knows how to write redirects in the configuration format
+// of a specific web server or hosting provider
+type RedirectFormat struct {
+	Name string
+	// FileName is the name of the file the redirects are written to
+	FileName string
+	// Render returns the content of the redirects file
+	Render func(redirects []*Redirect, conf *Config) string
+}
+
+// redirectFormats are selected by name with the -redirects option. Each
+// format writes a single file to the output directory, so the file name has
+// to be unique among the formats, and Render gets every redirect of the run at
+// once, already relative to the site root.
+var redirectFormats = []*RedirectFormat{
+	{
+		// /a-b-tests-developers-manual-f57f5c1a492  /post/2018-09-25_a-b-tests-developers-manual/  301
+		Name:     "netlify",
+		FileName: "_redirects",
+		Render: func(redirects []*Redirect, conf *Config) string {
+			var b strings.Builder
+			for _, r := range redirects {
+				b.WriteString(fmt.Sprintf("%s  %s  301\n", r.From, r.To))
+			}
+
+			return b.String()
+		},
+	},
+	{
+		// /a-b-tests-developers-manual-f57f5c1a492 /post/2018-09-25_a-b-tests-developers-manual/;
+		Name:     "nginx",
+		FileName: "redirects.map",
+		Render: func(redirects []*Redirect, conf *Config) string {
+			var b strings.Builder
+			b.WriteString("# include in the http block, and redirect in the server block with\n")
+			b.WriteString("# if ($medium_redirect) { return 301 $medium_redirect; }\n")
+			b.WriteString("map $uri $medium_redirect {\n")
+			for _, r := range redirects {
+				b.WriteString(fmt.Sprintf("    %s %s;\n", nginxQuote(r.From), nginxQuote(r.To)))
+			}
+			b.WriteString("}\n")
+
+			return b.String()
+		},
+	},
+	{
+		// Redirect 301 /a-b-tests-developers-manual-f57f5c1a492 /post/2018-09-25_a-b-tests-developers-manual/
+		Name:     "apache",
+		FileName: ".htaccess",
+		Render: func(redirects []*Redirect, conf *Config) string {
+			var b strings.Builder
+			for _, r := range redirects {
+				b.WriteString(fmt.Sprintf("Redirect 301 %q %q\n", r.From, r.To))
+			}
+
+			return b.String()
+		},
+	},
+	{
+		// example.com/a-b-tests-developers-manual-f57f5c1a492,https://example.com/post/2018-09-25_a-b-tests-developers-manual/,301
+		Name:     "cloudflare",
+		FileName: "redirects.csv",
+		Render: func(redirects []*Redirect, conf *Config) string {
+			// bulk redirect sources are urls without the scheme
+			source := conf.SiteURL
+			if u, err := url.Parse(conf.SiteURL); err == nil && len(u.Host) > 0 {
+				source = u.Host + strings.TrimSuffix(u.Path, "/")
+			}
+
+			var b strings.Builder
+			for _, r := range redirects {
+				b.WriteString(fmt.Sprintf("%s%s,%s%s,301\n", source, r.From, conf.SiteURL, r.To))
+			}
+
+			return b.String()
+		},
+	},
+}
+
+// findRedirectFormat returns the RedirectFormat with the given name, nil if
+// the format isn't supported
+func findRedirectFormat(name string) *RedirectFormat {
+	for _, f := range redirectFormats {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// writeRedirects writes the given redirects in each of the given formats to
+// the given directory
+func writeRedirects(dir string, formats []string, redirects []*Redirect, conf *Config) error {
+	for _, name := range formats {
+		f := findRedirectFormat(name)
+		if f == nil {
+			return fmt.Errorf("unknown redirect format: %s", name)
+		}
+
+		err := ioutil.WriteFile(filepath.Join(dir, f.FileName), []byte(f.Render(redirects, conf)), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nginxQuote quotes the given value for nginx configuration if it contains
+// characters that have a special meaning
+func nginxQuote(v string) string {
+	if strings.ContainsAny(v, " ;{}#'\"$") {
+		return fmt.Sprintf("%q", v)
+	}
+
+	return v
+}
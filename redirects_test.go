@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteRedirects(t *testing.T) {
+	redirects := []*Redirect{
+		{From: "/a-b-tests-developers-manual-f57f5c1a492", To: "/post/2018-09-25_a-b-tests-developers-manual/"},
+		{From: "/@author/a-b-tests-developers-manual-f57f5c1a492", To: "/post/2018-09-25_a-b-tests-developers-manual/"},
+		{From: "/p/1c2d3e4f5a6", To: "/post/2019-01-10_tabs-and-spaces/"},
+	}
+
+	conf := &Config{SiteURL: "https://example.com/blog"}
+	golden := map[string]string{
+		"netlify":    "redirects-netlify.txt",
+		"nginx":      "redirects-nginx.txt",
+		"apache":     "redirects-apache.txt",
+		"cloudflare": "redirects-cloudflare.txt",
+	}
+
+	dir := t.TempDir()
+	formats := []string{"netlify", "nginx", "apache", "cloudflare"}
+	if err := writeRedirects(dir, formats, redirects, conf); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range formats {
+		t.Run(name, func(t *testing.T) {
+			content, err := ioutil.ReadFile(filepath.Join(dir, findRedirectFormat(name).FileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, want := string(content), readGolden(t, golden[name])+"\n"; got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	if err := writeRedirects(dir, []string{"caddy"}, redirects, conf); err == nil {
+		t.Errorf("expected an error for an unknown redirect format")
+	}
+}
@@ -1,140 +1,19 @@
 package main
 
 import (
-	"crypto/tls"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/chamilad/html-to-markdown"
-	"io/ioutil"
-	"net/http"
-	"os"
-	"path/filepath"
+	"html"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
-var ruleOverrides = []md.Rule{
-	// converter rule to convert github gists to markdown code blocks
-	{
-		//<figure name="3f51" id="3f51" class="graf graf--figure graf--iframe graf-after--p">
-		// <script src="https://gist.github.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a.js"></script>
-		// </figure>
-		Filter: []string{"script"},
-		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
-			codeContentType := ""
-			codeContent := ""
-
-			// check the src attribute
-			src, exists := selec.Attr("src")
-			if !exists {
-				// if src cannot be found, nothing can be done
-				printRedDot()
-				return nil
-			}
-
-			// if src exists, check if it is a gist
-			if !strings.HasPrefix(src, "https://gist.github") {
-				return nil
-			}
-
-			// remove the js extension from the path
-			// https://gist.github.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a.js
-			src = src[0 : len(src)-len(filepath.Ext(src))]
-			//fmt.Printf("gist source path: %s", src)
-
-			// get the content type from the html content
-			skipTLS := strings.ToLower(os.Getenv("ALLOW_INSECURE")) == "true"
-			tr := &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLS},
-			}
-
-			client := &http.Client{Transport: tr}
-
-			res, err := client.Get(src)
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			htmlDoc, err := goquery.NewDocumentFromReader(res.Body)
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			err = res.Body.Close()
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			//<meta
-			//class="js-ga-set"
-			//name="dimension7"
-			//content="xml"
-			//>
-			htmlDoc.Find("meta[name='dimension7']").Each(func(i int, selection *goquery.Selection) {
-				ct, exists := selection.Attr("content")
-				if !exists {
-					printRedDot()
-					return
-				}
-
-				// certain content types don't translate well to markdown
-				if ct == "unknown" {
-					codeContentType = ""
-				} else if ct == "shell" {
-					codeContentType = "bash"
-				} else {
-					codeContentType = ct
-				}
-			}) // content type reading done
-
-			// get raw content
-			rawsrc := fmt.Sprintf(
-				"%s/raw",
-				strings.Replace(src, "gist.github.com", "gist.githubusercontent.com", 1))
-
-			rawres, err := client.Get(rawsrc)
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			resBody, err := ioutil.ReadAll(rawres.Body)
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			err = rawres.Body.Close()
-			if err != nil {
-				printRedDot()
-				return nil
-			}
-
-			codeContent = string(resBody) // reading raw content done
-
-			// if no raw content is read, return without rendering
-			if len(codeContent) == 0 {
-				return nil
-			}
-
-			// otherwise render a markdown code block with content type
-			codeblock := fmt.Sprintf(
-				"\n\n%s%s\n%s\n%s\n\n",
-				options.Fence,
-				codeContentType,
-				codeContent,
-				options.Fence)
-
-			printDot()
-			return md.String(codeblock)
-		},
-
-		AdvancedReplacement: nil,
-	},
+// listItemLineStart matches the start of every line of a list item after the first, to be indented
+var listItemLineStart = regexp.MustCompile(`\n(.)`)
 
+var ruleOverrides = []md.Rule{
 	// convert remaining br tags to new line chars
 	{
 		Filter: []string{"br"},
@@ -158,9 +37,17 @@ var ruleOverrides = []md.Rule{
 				return md.String("")
 			}
 
+			// code blocks with inline formatting are marked before the conversion if the formatting should be
+			// preserved by rendering them as html
+			_, asHTML := selec.Attr(CodeHTMLAttr)
+			read := readCodeContent
+			if asHTML {
+				read = readCodeHTML
+			}
+
 			// read code for current pre block
 			codeContent := ""
-			readCodeContent(selec, &codeContent)
+			read(selec, &codeContent)
 
 			// check if next element is a pre block, and read content if so
 			nextSelec := selec.Next()
@@ -173,7 +60,7 @@ var ruleOverrides = []md.Rule{
 				codeContent += "\n\n"
 
 				// append content to single block
-				readCodeContent(nextSelec, &codeContent)
+				read(nextSelec, &codeContent)
 
 				// mark pre block as collected
 				nextSelec.AddClass("m2h-collected")
@@ -182,30 +69,19 @@ var ruleOverrides = []md.Rule{
 				nextSelec = nextSelec.Next()
 			}
 
-			return md.String(fmt.Sprintf("\n\n%s\n%s\n%s\n\n", options.Fence, codeContent, options.Fence))
-		},
-		AdvancedReplacement: nil,
-	},
+			// the language of the code block is marked before the conversion
+			lang := selec.AttrOr(CodeLanguageAttr, "")
 
-	// convert slideshare links to proper iframe embeds
-	{
-		// <figure name="9af0" id="9af0" class="graf graf--figure graf--iframe graf-after--blockquote">
-		// <iframe src="https://www.slideshare.net/slideshow/embed_code/key/8br68UFQtb7qpF" width="600" height="500"
-		// frameborder="0" scrolling="no"></iframe>
-		// </figure>
-		Filter: []string{"iframe"},
-		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
-			src, exists := selec.Attr("src")
-			if !exists || !strings.Contains(src, "slideshare.net") {
-				return nil
-			}
+			if asHTML {
+				class := ""
+				if len(lang) > 0 {
+					class = fmt.Sprintf(" class=\"language-%s\"", lang)
+				}
 
-			return md.String(fmt.Sprintf(
-				"<iframe src=\"%s\" width=\"595\" height=\"485\" frameborder=\"0\" marginwidth=\"0\" "+
-					"marginheight=\"0\" scrolling=\"no\" style=\"border:1px solid #CCC; border-width:1px; "+
-					"margin-bottom:5px; \" allowfullscreen> </iframe>\n",
-				src))
+				return md.String(fmt.Sprintf("\n\n<pre><code%s>%s</code></pre>\n\n", class, codeContent))
+			}
 
+			return md.String(fmt.Sprintf("\n\n%s%s\n%s\n%s\n\n", options.Fence, lang, codeContent, options.Fence))
 		},
 		AdvancedReplacement: nil,
 	},
@@ -297,24 +173,59 @@ var ruleOverrides = []md.Rule{
 		AdvancedReplacement: nil,
 	},
 
-	// handle tweets
-	// have to use hugo shortcodes here since there is no easy way to generate a twitter embed code
+	// keep drop caps as inline html so they can be styled
 	{
-		Filter: []string{"blockquote"},
+		// <p class="graf graf--p graf--hasDropCapModel graf--hasDropCap"><span class="graf-dropCap">T</span>he...
+		Filter: []string{"span"},
 		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
-			// check if a tweet
-			if !selec.HasClass("twitter-tweet") {
-				return nil
+			// there's no default rule for span to fall back to
+			if !selec.HasClass("graf-dropCap") {
+				return md.String(content)
 			}
 
-			t, exists := selec.Find("a").Attr("href")
-			if !exists {
+			return md.String(fmt.Sprintf("<span class=\"drop-cap\">%s</span>", html.EscapeString(selec.Text())))
+		},
+		AdvancedReplacement: nil,
+	},
+
+	// keep nested lists on their own lines, the default rule joins a nested list with the text of the parent item
+	{
+		// <li>item<ul><li>nested</li></ul></li>
+		Filter: []string{"ul", "ol"},
+		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+			if !selec.Parent().Is("li") {
 				return nil
 			}
 
-			splits := strings.Split(t, "/")
-			st := splits[len(splits)-1]
-			return md.String(fmt.Sprintf("{{< tweet %s >}}", st))
+			return md.String("\n" + strings.Trim(content, "\n") + "\n")
+		},
+		AdvancedReplacement: nil,
+	},
+
+	// number ordered list items from the start number of the list, which is set by medium or when continuing a
+	// list interrupted by images or code
+	{
+		// <ol class="postList" start="3"><li name="8c1d" id="8c1d" class="graf graf--li">item</li></ol>
+		Filter: []string{"li"},
+		Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+			parent := selec.Parent()
+
+			prefix := options.BulletListMarker + " "
+			if parent.Is("ol") {
+				start, err := strconv.Atoi(parent.AttrOr("start", "1"))
+				if err != nil {
+					start = 1
+				}
+
+				prefix = fmt.Sprintf("%d. ", start+selec.Index())
+			}
+
+			// remove leading and trailing newlines, and indent the rest of the lines so nested lists and
+			// paragraphs stay in the item
+			content = strings.Trim(content, "\n")
+			content = listItemLineStart.ReplaceAllString(content, "\n    $1")
+
+			return md.String(prefix + content + "\n")
 		},
 		AdvancedReplacement: nil,
 	},
@@ -364,3 +275,337 @@ func readCodeContent(s *goquery.Selection, c *string) {
 		}
 	})
 }
+
+// configuredRules returns the converter rules whose output depends on the user provided options
+func configuredRules(conf *Config) []md.Rule {
+	return []md.Rule{
+		// converter rule to convert github gists to markdown code blocks
+		{
+			//<figure name="3f51" id="3f51" class="graf graf--figure graf--iframe graf-after--p">
+			// <script src="https://gist.github.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a.js"></script>
+			// </figure>
+			Filter: []string{"script"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				// check the src attribute
+				src, exists := selec.Attr("src")
+				if !exists {
+					// if src cannot be found, nothing can be done
+					printRedDot()
+					return nil
+				}
+
+				// if src exists, check if it is a gist
+				if !strings.HasPrefix(src, "https://gist.github") {
+					return nil
+				}
+
+				// a gist can have multiple files, or the embed can select a specific file with the file query param
+				// https://gist.github.com/chamilad/63cfa08c052e795c8e95bb7b43643f6a.js?file=deploy.sh
+				files, err := fetchGistFiles(src)
+				if err != nil {
+					printRedDot()
+					return nil
+				}
+
+				// render a markdown code block with content type for each file, labeled with the filename. The raw
+				// content fallback doesn't know the filename unless a file is selected.
+				codeblocks := ""
+				for _, f := range files {
+					if len(f.Filename) > 0 {
+						codeblocks += fmt.Sprintf("\n\n`%s`", f.Filename)
+					}
+
+					codeblocks += fmt.Sprintf(
+						"\n\n%s%s\n%s\n%s\n\n",
+						options.Fence,
+						f.CodeLanguage(conf.Languages),
+						f.Content,
+						options.Fence)
+				}
+
+				// if no raw content is read, return without rendering
+				if len(codeblocks) == 0 {
+					return nil
+				}
+
+				printDot()
+				return md.String(codeblocks)
+			},
+
+			AdvancedReplacement: nil,
+		},
+
+		// handle tweets
+		// the embed depends on the output target, or a static blockquote can be rendered to avoid depending on
+		// twitter at build time
+		{
+			// <blockquote class="twitter-tweet"><p lang="en" dir="ltr">tweet text</p>&mdash; Author (@handle)
+			// <a href="https://twitter.com/handle/status/1083326536512397312">January 10, 2019</a></blockquote>
+			Filter: []string{"blockquote"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				// check if a tweet
+				if !selec.HasClass("twitter-tweet") {
+					return nil
+				}
+
+				t := newTweet(selec)
+				if t == nil {
+					return nil
+				}
+
+				if conf.Tweets == TweetsStatic {
+					return md.String(t.StaticMarkdown())
+				}
+
+				return md.String(t.Embed(conf.Target))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render pull quotes distinguishably from other blockquotes
+		{
+			// <blockquote name="4d2e" id="4d2e" class="graf graf--pullquote graf-after--p">quote</blockquote>
+			Filter: []string{"blockquote"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				if !selec.HasClass("graf--pullquote") || conf.Pullquotes == PullquotesBlockquote {
+					return nil
+				}
+
+				return md.String(renderPullquote(strings.TrimSpace(content), conf))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render anchored headings, keeping the medium anchor as an alternate so old deep links still work
+		{
+			// <h3 name="3f51" id="3f51" class="graf graf--h3" data-m2h-anchor="getting-started">Getting Started</h3>
+			Filter: []string{"h1", "h2", "h3", "h4", "h5", "h6"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				name := selec.AttrOr("name", "")
+				anchor, exists := selec.Attr(HeadingAnchorAttr)
+				if !exists {
+					// keep the medium anchor as the only anchor if the paragraph anchors are kept
+					if !conf.KeepParagraphAnchors() || len(name) == 0 {
+						return nil
+					}
+
+					anchor = name
+				}
+
+				heading := "\n\n"
+				if len(name) > 0 && name != anchor {
+					heading += fmt.Sprintf("<a id=\"%s\"></a>\n", name)
+				}
+
+				// heading attributes aren't portable
+				if conf.Target == TargetHTML {
+					heading += fmt.Sprintf("<a id=\"%s\"></a>\n", anchor)
+				}
+
+				content = strings.TrimSpace(strings.NewReplacer("\n", " ", "\r", " ").Replace(content))
+				heading += fmt.Sprintf("%s %s", strings.Repeat("#", headingLevel(selec)), content)
+				if conf.Target != TargetHTML {
+					heading += fmt.Sprintf(" {#%s}", anchor)
+				}
+
+				return md.String(heading + "\n\n")
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// keep medium paragraph anchors used in deep links
+		{
+			// <p name="5b8a" id="5b8a" class="graf graf--p graf-after--h3">text</p>
+			Filter: []string{"p"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				name := selec.AttrOr("name", "")
+				if !conf.KeepParagraphAnchors() || len(name) == 0 {
+					return nil
+				}
+
+				content = strings.TrimSpace(content)
+				if len(content) == 0 {
+					return nil
+				}
+
+				// block attributes aren't portable
+				if conf.ParagraphAnchors == ParagraphAnchorsHTML || conf.Target == TargetHTML {
+					return md.String(fmt.Sprintf("\n\n<a id=\"%s\"></a>%s\n\n", name, content))
+				}
+
+				// kramdown uses a different syntax for block attributes
+				if conf.Target == TargetJekyll {
+					return md.String(fmt.Sprintf("\n\n%s\n{: #%s}\n\n", content, name))
+				}
+
+				return md.String(fmt.Sprintf("\n\n%s\n{#%s}\n\n", content, name))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render footnotes, placed after the paragraph anchors so that footnote paragraphs aren't anchored
+		{
+			// <p data-m2h-fntext="1">footnote</p>
+			Filter: []string{"p"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				label, exists := selec.Attr(FootnoteTextAttr)
+				if !exists {
+					return nil
+				}
+
+				// continuation lines of the footnote are indented
+				content = strings.Replace(strings.TrimSpace(content), "\n", "\n    ", -1)
+				return md.String(fmt.Sprintf("\n\n[^%s]: %s\n\n", label, content))
+			},
+			AdvancedReplacement: nil,
+		},
+		{
+			// <sup data-m2h-fn="1">1</sup>
+			Filter: []string{"sup"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				label, exists := selec.Attr(FootnoteAttr)
+				if !exists {
+					// there's no default rule for sup to fall back to
+					return md.String(content)
+				}
+
+				return md.String(fmt.Sprintf("[^%s]", label))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render highlights and quote annotations
+		{
+			// <mark class="markup--highlight markup--p-highlight">highlighted text</mark>
+			// <span class="markup--quote markup--p-quote is-me" name="...">quoted text</span>
+			Filter: []string{"mark", "span"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				if goquery.NodeName(selec) == "span" &&
+					!selec.HasClass("markup--highlight") && !selec.HasClass("markup--quote") {
+					return nil
+				}
+
+				return md.String(renderHighlight(content, conf))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render link preview cards, these are replaced with placeholders before the conversion if the link cards
+		// should be kept
+		{
+			Filter: []string{"div"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				// there's no default rule for div to fall back to
+				if !selec.HasClass(LinkCardClass) {
+					return md.String(content)
+				}
+
+				return md.String(renderLinkCard(selec, conf))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// render the equations recovered from equation images as LaTeX
+		{
+			// <span data-m2h-math="E=mc^2" data-m2h-block></span>
+			Filter: []string{"span"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				latex, exists := selec.Attr(EquationAttr)
+				if !exists {
+					return nil
+				}
+
+				_, display := selec.Attr(EquationDisplayAttr)
+				return md.String(renderEquation(&Equation{LaTeX: latex, Display: display}, conf.Target))
+			},
+			AdvancedReplacement: nil,
+		},
+
+		// convert iframe embeds, either direct or wrapped by embedly, using the registered embed providers
+		{
+			// <figure name="c5a1" id="c5a1" class="graf graf--figure graf--iframe graf-after--p">
+			// <iframe src="https://cdn.embedly.com/widgets/media.html?src=https%3A%2F%2Fwww.youtube.com%2Fembed%2FdQw4w9WgXcQ
+			// %3Ffeature%3Doembed&url=http%3A%2F%2Fwww.youtube.com%2Fwatch%3Fv%3DdQw4w9WgXcQ&type=text%2Fhtml
+			// &schema=youtube" width="640" height="480" frameborder="0" scrolling="no"></iframe>
+			// </figure>
+			Filter: []string{"iframe"},
+			Replacement: func(content string, selec *goquery.Selection, options *md.Options) *string {
+				e := newEmbed(selec)
+				if e == nil {
+					return nil
+				}
+
+				return renderEmbed(e, conf)
+			},
+			AdvancedReplacement: nil,
+		},
+	}
+}
+
+// readCodeHTML reads the content of a given Selection as HTML, keeping the bold, italic and link elements and
+// honouring the br tags found within the text. Any other elements are flattened to their escaped text. This is
+// intended to be used to read content within pre blocks that should be rendered as HTML.
+// The read HTML will be appended to the string provided by the pointer
+func readCodeHTML(s *goquery.Selection, c *string) {
+	s.Contents().Each(func(i int, selection *goquery.Selection) {
+		switch goquery.NodeName(selection) {
+		case "#text":
+			*c += html.EscapeString(selection.Text())
+		case "br":
+			*c += "\n"
+		case "strong", "b":
+			*c += "<strong>"
+			readCodeHTML(selection, c)
+			*c += "</strong>"
+		case "em", "i":
+			*c += "<em>"
+			readCodeHTML(selection, c)
+			*c += "</em>"
+		case "a":
+			*c += fmt.Sprintf("<a href=\"%s\">", html.EscapeString(selection.AttrOr("href", "")))
+			readCodeHTML(selection, c)
+			*c += "</a>"
+		default:
+			readCodeHTML(selection, c)
+		}
+	})
+}
+
+// renderPullquote renders the given pull quote markdown as a pullquote shortcode of the output target, or as a
+// blockquote with the pullquote class. The markdown content is kept outside the html tags, separated by blank
+// lines, so that it's still rendered as markdown.
+func renderPullquote(content string, conf *Config) string {
+	if conf.Pullquotes == PullquotesShortcode {
+		switch conf.Target {
+		case TargetHugo:
+			return fmt.Sprintf("\n\n{{< pullquote >}}\n%s\n{{< /pullquote >}}\n\n", content)
+		case TargetJekyll:
+			return fmt.Sprintf("\n\n{%% capture pullquote %%}\n%s\n{%% endcapture %%}\n"+
+				"{%% include pullquote.html content=pullquote %%}\n\n", content)
+		case TargetZola:
+			return fmt.Sprintf("\n\n{%% pullquote() %%}\n%s\n{%% end %%}\n\n", content)
+		}
+	}
+
+	return fmt.Sprintf("\n\n<blockquote class=\"pullquote\">\n\n%s\n\n</blockquote>\n\n", content)
+}
+
+// renderHighlight renders the given highlighted markdown as a mark element, or as a mark shortcode of the output
+// target
+func renderHighlight(content string, conf *Config) string {
+	switch conf.Highlights {
+	case HighlightsText:
+		return content
+	case HighlightsShortcode:
+		switch conf.Target {
+		case TargetHugo:
+			return fmt.Sprintf("{{< mark >}}%s{{< /mark >}}", content)
+		case TargetJekyll:
+			return fmt.Sprintf("{%% include mark.html text=%s %%}", quoteParam(content))
+		case TargetZola:
+			return fmt.Sprintf("{{ mark(text=%s) }}", quoteParam(content))
+		}
+	}
+
+	return fmt.Sprintf("<mark>%s</mark>", content)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPullquoteRendering(t *testing.T) {
+	pullquote := `<blockquote class="graf graf--pullquote">Ship <strong>small</strong> changes</blockquote>`
+
+	tests := []struct {
+		name       string
+		target     string
+		pullquotes string
+		want       string
+	}{
+		{
+			name:       "blockquote",
+			target:     TargetHugo,
+			pullquotes: PullquotesBlockquote,
+			want:       "> Ship **small** changes",
+		},
+		{
+			name:       "html",
+			target:     TargetHugo,
+			pullquotes: PullquotesHTML,
+			want:       "<blockquote class=\"pullquote\">\n\nShip **small** changes\n\n</blockquote>",
+		},
+		{
+			name:       "hugo shortcode",
+			target:     TargetHugo,
+			pullquotes: PullquotesShortcode,
+			want:       "{{< pullquote >}}\nShip **small** changes\n{{< /pullquote >}}",
+		},
+		{
+			name:       "jekyll shortcode",
+			target:     TargetJekyll,
+			pullquotes: PullquotesShortcode,
+			want: "{% capture pullquote %}\nShip **small** changes\n{% endcapture %}\n" +
+				"{% include pullquote.html content=pullquote %}",
+		},
+		{
+			name:       "zola shortcode",
+			target:     TargetZola,
+			pullquotes: PullquotesShortcode,
+			want:       "{% pullquote() %}\nShip **small** changes\n{% end %}",
+		},
+		{
+			name:       "shortcode without shortcodes in the target",
+			target:     TargetHTML,
+			pullquotes: PullquotesShortcode,
+			want:       "<blockquote class=\"pullquote\">\n\nShip **small** changes\n\n</blockquote>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertHTML(t, Config{Target: tt.target, Pullquotes: tt.pullquotes}, pullquote)
+			if got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	// other blockquotes are not affected
+	got := convertHTML(t, Config{Target: TargetHugo, Pullquotes: PullquotesHTML}, `<blockquote class="graf graf--blockquote">quote</blockquote>`)
+	if got != "> quote" {
+		t.Errorf("got %q for a blockquote, want %q", got, "> quote")
+	}
+}
+
+func TestDropCapRendering(t *testing.T) {
+	got := convertHTML(t, Config{Target: TargetHugo},
+		`<p class="graf graf--p graf--hasDropCap"><span class="graf-dropCap">T</span>he <span>start</span> of it</p>`)
+	if want := `<span class="drop-cap">T</span>he start of it`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestHeadingAnchorRendering(t *testing.T) {
+	heading := `<h3 name="3f51" id="3f51" data-m2h-anchor="getting-started">Getting Started</h3>`
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{TargetHugo, "<a id=\"3f51\"></a>\n### Getting Started {#getting-started}"},
+		{TargetHTML, "<a id=\"3f51\"></a>\n<a id=\"getting-started\"></a>\n### Getting Started"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := convertHTML(t, Config{Target: tt.target}, heading); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParagraphAnchorRendering(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		anchors string
+		want    string
+	}{
+		{"html", TargetHugo, ParagraphAnchorsHTML, "paragraph-anchors-html.md"},
+		{"attributes", TargetHugo, ParagraphAnchorsAttributes, "paragraph-anchors-attributes.md"},
+		{"jekyll attributes", TargetJekyll, ParagraphAnchorsAttributes, "paragraph-anchors-attributes-jekyll.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertHTML(t, Config{Target: tt.target, ParagraphAnchors: tt.anchors}, readGolden(t, "paragraph-anchors.html"))
+			if want := readGolden(t, tt.want); got != want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -2,18 +2,26 @@ package main
 
 import (
 	"archive/zip"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/fatih/color"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// HTTPTimeout is the time limit of the http requests made by the clients from
+// newHTTPClient, so that an unresponsive server doesn't stall a run
+const HTTPTimeout = 30 * time.Second
+
 // generateSlug generates a filesystem friendly filename from a given post
 // title by removing special characters
 func generateSlug(s string) string {
@@ -53,6 +61,72 @@ func extractMediumImageStyle(imgDomElement *goquery.Selection) (mediumImageStyle
 	return
 }
 
+// headingLevel returns the level of the given heading element, h1 => 1
+func headingLevel(h *goquery.Selection) int {
+	level, err := strconv.Atoi(strings.TrimPrefix(goquery.NodeName(h), "h"))
+	if err != nil || level < 1 || level > 6 {
+		return 6
+	}
+
+	return level
+}
+
+// mixtapeBackgroundImage matches the thumbnail url in the style of a link
+// preview card thumbnail
+// style="background-image: url(https://cdn-images-1.medium.com/fit/c/160/160/0*abc.jpeg);"
+var mixtapeBackgroundImage = regexp.MustCompile(`url\(['"]?([^'")]+)['"]?\)`)
+
+// mixtapeThumbnail reads the thumbnail url of a Medium link preview card from
+// the given thumbnail anchor, an empty string if there's no thumbnail
+func mixtapeThumbnail(a *goquery.Selection) string {
+	if a.Length() == 0 {
+		return ""
+	}
+
+	if m := mixtapeBackgroundImage.FindStringSubmatch(a.AttrOr("style", "")); len(m) > 1 {
+		return m[1]
+	}
+
+	if id := a.AttrOr("data-thumbnail-img-id", ""); len(id) > 0 {
+		return fmt.Sprintf("https://cdn-images-1.medium.com/fit/c/160/160/%s", id)
+	}
+
+	return ""
+}
+
+// mediumPostIDPattern matches the hex post id at the end of the last path
+// segment of a Medium post url
+var mediumPostIDPattern = regexp.MustCompile(`(?:^|-)([0-9a-f]{8,16})$`)
+
+// mediumPostID returns the id of the Medium post at the given url, the hex
+// suffix of the last path segment, an empty string if there's none
+//
+// https://medium.com/@user/a-b-tests-developers-manual-f57f5c1a492 => f57f5c1a492
+func mediumPostID(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	if m := mediumPostIDPattern.FindStringSubmatch(lastPathSegment(parsed.Path)); m != nil {
+		return m[1]
+	}
+
+	return ""
+}
+
+// splitList splits the given comma separated list, dropping empty values
+func splitList(s string) []string {
+	result := make([]string, 0)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); len(v) > 0 {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
 // downloadFile will download a url to a local file.
 func downloadFile(url, filepath string) error {
 	// Create the file
@@ -78,6 +152,18 @@ func downloadFile(url, filepath string) error {
 	return nil
 }
 
+// newHTTPClient returns a client to be used for any http requests, which
+// gives up after HTTPTimeout. TLS verification is skipped if ALLOW_INSECURE
+// environment variable is set to true
+func newHTTPClient() *http.Client {
+	skipTLS := strings.ToLower(os.Getenv("ALLOW_INSECURE")) == "true"
+	tr := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLS},
+	}
+
+	return &http.Client{Transport: tr, Timeout: HTTPTimeout}
+}
+
 // fileExists checks if the given file exists
 // returns the absolute path if it does
 func fileExists(f string) (bool, string) {
@@ -210,7 +296,7 @@ func cleanup(mgr *ConverterManager) {
 // printError prints the given string formatted with the subsequent arguments
 // to the stdout in red color
 func printError(msg string, a ...interface{}) {
-	color.Red(msg, a)
+	color.Red(msg, a...)
 }
 
 // printDot prints a dot char to the stdout
@@ -220,24 +306,24 @@ func printDot() {
 
 // printRedDot prints a red dot to the stdout
 func printRedDot() {
-	fmt.Printf(color.New(color.FgHiRed).Sprint("%c", DotMark))
+	fmt.Print(color.New(color.FgHiRed).Sprintf("%c", DotMark))
 }
 
 // printCheckMark prints a unicode check mark to the stdout in green color
 func printCheckMark() {
-	fmt.Printf(color.New(color.FgHiGreen, color.Bold).Sprintf("%c", CheckMark))
+	fmt.Print(color.New(color.FgHiGreen, color.Bold).Sprintf("%c", CheckMark))
 }
 
 // printXMark prints a unicode x mark to the stdout in red color
 func printXMark() {
-	fmt.Printf(color.New(color.FgHiRed, color.Bold).Sprintf("%c", XMark))
+	fmt.Print(color.New(color.FgHiRed, color.Bold).Sprintf("%c", XMark))
 }
 
 // printXError prints a unicode cross mark to the stdout in red
 // Used to indicate a failure of a task, the reason for failure is also
 // expected as a formattable string
 func printXError(msg string, a ...interface{}) {
-	fmt.Printf("%s ", color.New(color.BgHiRed, color.FgHiWhite).Sprintf(msg, a))
+	fmt.Printf("%s ", color.New(color.BgHiRed, color.FgHiWhite).Sprintf(msg, a...))
 	printXMark()
 }
 